@@ -1,26 +1,26 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"crypto/md5"
 	"fmt"
 	"html/template"
 	"log"
+	"math"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
-	"unicode"
 
 	"github.com/rosscartlidge/gogstools/gs"
+	"github.com/rosscartlidge/gogstools/gs/tsv"
 )
 
 // ChartConfig defines the configuration for the chart command
 type ChartConfig struct {
 	X      string                      `gs:"field,global,last,help=Use field for X axis"`
-	Y      []string                    `gs:"field,local,list,help=Use field for Y axis"`
-	Match  []map[string]interface{}    `gs:"multi,local,list,args=field:content,help=Filter data by field matching content"`
+	Y      []string                    `gs:"field,local,list,kind=numeric,help=Use field for Y axis"`
+	Match  []matchArg                  `gs:"multi,local,list,args=field:content,help=Filter data by field matching content"`
+	gs.Predicates // -eq, -lt, -gt, -range, -in, -null and friends
 	Right  bool                        `gs:"flag,local,last,help=Use right-hand scale"`
 	Title  string                      `gs:"string,global,last,help=Chart title,default=Chart"`
 	Type   string                      `gs:"string,global,last,help=Chart type: bar/line/area,default=bar,enum=bar:line:area"`
@@ -30,10 +30,26 @@ type ChartConfig struct {
 	Argv   string                      `gs:"file,global,last,help=Input TSV file,suffix=.[tc]sv"`
 }
 
+// chartClause holds the per-clause switches of ChartConfig; -match and the
+// gs.Predicates switches are applied through gs.NewFilter. A +y field is
+// excluded rather than plotted.
+type chartClause struct {
+	Y     []gs.Negatable[string]
+	Right bool
+}
+
+// matchArg is a single -match field content condition; the switch's
+// arguments are bound to its fields in order
+type matchArg struct {
+	Field   string
+	Content string
+	Negated bool
+}
+
 // Dataset represents a Chart.js dataset
 type Dataset struct {
 	Label           string    `json:"label"`
-	Data            []float64 `json:"data"`
+	Data            []float64 `json:"data"` // NaN for a gap
 	BackgroundColor string    `json:"backgroundColor"`
 	BorderColor     string    `json:"borderColor"`
 	YAxisID         string    `json:"yAxisID,omitempty"`
@@ -88,8 +104,7 @@ func (cfg *ChartConfig) getInputFile(clauses []gs.ClauseSet) string {
 		if args, ok := clause.Fields["_args"]; ok {
 			if argList, ok := args.([]string); ok && len(argList) > 0 {
 				for _, arg := range argList {
-					if strings.HasSuffix(strings.ToLower(arg), ".tsv") || 
-					   strings.HasSuffix(strings.ToLower(arg), ".csv") {
+					if tsv.IsDataFile(arg) {
 						return arg
 					}
 				}
@@ -101,66 +116,30 @@ func (cfg *ChartConfig) getInputFile(clauses []gs.ClauseSet) string {
 	return "-"
 }
 
-// parseTSV reads and parses a TSV/CSV file or stdin
-func parseTSV(filename string) (*TSVData, error) {
-	var reader *bufio.Scanner
-	var closeFn func() error
-	
-	// Handle stdin vs file input
+// inputName returns a display name for an input file
+func inputName(filename string) string {
 	if filename == "" || filename == "-" {
-		reader = bufio.NewScanner(os.Stdin)
-		closeFn = func() error { return nil } // Don't close stdin
-	} else {
-		file, err := os.Open(filename)
-		if err != nil {
-			return nil, fmt.Errorf("opening file %s: %w", filename, err)
-		}
-		reader = bufio.NewScanner(file)
-		closeFn = file.Close
+		return "stdin"
 	}
-	defer closeFn()
-	
-	// Read first line to detect separator
-	if !reader.Scan() {
-		if err := reader.Err(); err != nil {
-			return nil, fmt.Errorf("reading first line: %w", err)
-		}
-		return nil, fmt.Errorf("no data in input")
-	}
-	
-	firstLine := reader.Text()
-	
-	// Find separator: first non-variable character, default to tab
-	separator := '\t'
-	for _, r := range firstLine {
-		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
-			separator = r
-			break
-		}
-	}
-	
-	// Parse first line as headers
-	headers := strings.Split(firstLine, string(separator))
-	for i, header := range headers {
-		headers[i] = strings.TrimSpace(header)
+	return filename
+}
+
+// parseTSV reads and parses a TSV/CSV file or stdin
+func parseTSV(ctx context.Context, filename string) (*TSVData, error) {
+	input, err := gs.OpenInput(ctx, filename)
+	if err != nil {
+		return nil, err
 	}
+	defer input.Close()
 	
-	// Parse remaining lines
-	var rows [][]string
-	for reader.Scan() {
-		line := reader.Text()
-		if line == "" {
-			continue // Skip empty lines
-		}
-		fields := strings.Split(line, string(separator))
-		for i, field := range fields {
-			fields[i] = strings.TrimSpace(field)
-		}
-		rows = append(rows, fields)
+	headers, records, err := tsv.NewReader(input).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", inputName(filename), err)
 	}
 	
-	if err := reader.Err(); err != nil {
-		return nil, fmt.Errorf("reading data: %w", err)
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		rows[i] = record
 	}
 	
 	return &TSVData{
@@ -179,51 +158,6 @@ func (data *TSVData) findFieldIndex(fieldName string) int {
 	return -1
 }
 
-// filterData applies match conditions to filter the TSV data
-func (data *TSVData) filterData(matches []map[string]interface{}) *TSVData {
-	if len(matches) == 0 {
-		return data
-	}
-	
-	filteredRows := [][]string{}
-	
-	for _, row := range data.Rows {
-		include := true
-		
-		// All match conditions in the same clause must be satisfied (AND logic)
-		for _, match := range matches {
-			fieldName, hasField := match["field"].(string)
-			content, hasContent := match["content"].(string)
-			
-			if !hasField || !hasContent {
-				continue
-			}
-			
-			fieldIndex := data.findFieldIndex(fieldName)
-			if fieldIndex == -1 || fieldIndex >= len(row) {
-				include = false
-				break
-			}
-			
-			// Simple regex matching
-			matched, err := regexp.MatchString(content, row[fieldIndex])
-			if err != nil || !matched {
-				include = false
-				break
-			}
-		}
-		
-		if include {
-			filteredRows = append(filteredRows, row)
-		}
-	}
-	
-	return &TSVData{
-		Headers: data.Headers,
-		Rows:    filteredRows,
-	}
-}
-
 // generateColor creates a deterministic color from field name using MD5
 func generateColor(fieldName string) (string, string) {
 	hash := md5.Sum([]byte(fieldName))
@@ -249,7 +183,7 @@ func (cfg *ChartConfig) Execute(ctx context.Context, clauses []gs.ClauseSet) err
 	}
 	
 	// Parse TSV data
-	data, err := parseTSV(inputFile)
+	data, err := parseTSV(ctx, inputFile)
 	if err != nil {
 		return fmt.Errorf("parsing TSV file: %w", err)
 	}
@@ -260,7 +194,7 @@ func (cfg *ChartConfig) Execute(ctx context.Context, clauses []gs.ClauseSet) err
 	
 	xIndex := data.findFieldIndex(cfg.X)
 	if xIndex == -1 {
-		return fmt.Errorf("X field '%s' not found in data", cfg.X)
+		return gs.UnknownFieldError(cfg.X, inputName(inputFile), data.Headers)
 	}
 	
 	// Process each clause to create datasets
@@ -273,100 +207,99 @@ func (cfg *ChartConfig) Execute(ctx context.Context, clauses []gs.ClauseSet) err
 		}
 	}
 	
-	for i, clause := range clauses {
-		// Apply filtering if match conditions exist
-		filteredData := data
-		if matches, ok := clause.Fields["Match"]; ok {
-			if matchList, ok := matches.([]interface{}); ok {
-				matchConditions := []map[string]interface{}{}
-				for _, m := range matchList {
-					if matchMap, ok := m.(map[string]interface{}); ok {
-						matchConditions = append(matchConditions, matchMap)
-					}
-				}
-				filteredData = data.filterData(matchConditions)
-			}
-		}
+	chartData.Datasets, err = cfg.datasets(data, clauses, inputFile)
+	if err != nil {
+		return err
+	}
+	
+	// Generate Chart.js configuration
+	err = cfg.generateChart(chartData)
+	if err != nil {
+		return fmt.Errorf("generating chart: %w", err)
+	}
+	
+	return nil
+}
+
+// datasets creates a dataset for each Y field of each clause, from the rows
+// the clause's predicate switches select
+func (cfg *ChartConfig) datasets(data *TSVData, clauses []gs.ClauseSet, inputFile string) ([]Dataset, error) {
+	chartClauses, err := gs.Clauses[chartClause](clauses)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Compile the -match and comparison switches of every clause against the header
+	filter, err := gs.NewFilter(clauses, gs.StandardPredicates, data.Headers)
+	if err != nil {
+		return nil, err
+	}
+	
+	datasets := []Dataset{}
+	for i, clause := range chartClauses {
+		// Each clause plots only the rows its predicate switches select
+		filteredData := &TSVData{Headers: data.Headers, Rows: filter.ClauseRows(i, data.Rows)}
+		useRightAxis := clause.Right
 		
-		// Determine if this clause uses right axis
-		useRightAxis := false
-		if right, ok := clause.Fields["Right"]; ok {
-			if rightBool, ok := right.(bool); ok {
-				useRightAxis = rightBool
+		// Create dataset for each Y field
+		for _, y := range clause.Y {
+			if y.Negated {
+				continue
+			}
+			yField := y.Value
+			yIndex := filteredData.findFieldIndex(yField)
+			if yIndex == -1 {
+				log.Printf("Warning: %v", gs.UnknownFieldError(yField, inputName(inputFile), data.Headers))
+				continue
 			}
-		}
-		
-		// Process Y fields for this clause
-		if yFields, ok := clause.Fields["Y"]; ok {
-			var yFieldNames []string
 			
-			// Handle both single fields and lists
-			if yList, ok := yFields.([]interface{}); ok {
-				for _, field := range yList {
-					if fieldStr, ok := field.(string); ok {
-						yFieldNames = append(yFieldNames, fieldStr)
+			// Extract numeric data; other values leave a gap rather than
+			// plotting as 0
+			yData := []float64{}
+			skipped := 0
+			for _, row := range filteredData.Rows {
+				if yIndex < len(row) {
+					if val, err := strconv.ParseFloat(strings.TrimSpace(row[yIndex]), 64); err == nil {
+						yData = append(yData, val)
+					} else {
+						yData = append(yData, math.NaN())
+						skipped++
 					}
 				}
-			} else if yField, ok := yFields.(string); ok {
-				yFieldNames = []string{yField}
+			}
+			if skipped > 0 {
+				log.Printf("Warning: skipped %d non-numeric values of %s", skipped, yField)
 			}
 			
-			// Create dataset for each Y field
-			for _, yField := range yFieldNames {
-				yIndex := filteredData.findFieldIndex(yField)
-				if yIndex == -1 {
-					log.Printf("Warning: Y field '%s' not found in data", yField)
-					continue
-				}
-				
-				// Extract numeric data
-				yData := []float64{}
-				for _, row := range filteredData.Rows {
-					if yIndex < len(row) {
-						if val, err := strconv.ParseFloat(row[yIndex], 64); err == nil {
-							yData = append(yData, val)
-						} else {
-							yData = append(yData, 0) // Default to 0 for non-numeric values
-						}
-					}
-				}
-				
-				// Generate deterministic colors
-				bgColor, borderColor := generateColor(yField)
-				
-				// Create dataset
-				dataset := Dataset{
-					Label:           yField,
-					Data:            yData,
-					BackgroundColor: bgColor,
-					BorderColor:     borderColor,
-					Fill:            cfg.Type == "area",
-				}
-				
-				if useRightAxis {
-					dataset.YAxisID = "y1"
-				} else {
-					dataset.YAxisID = "y"
-				}
-				
-				chartData.Datasets = append(chartData.Datasets, dataset)
+			// Generate deterministic colors
+			bgColor, borderColor := generateColor(yField)
+			
+			// Create dataset
+			dataset := Dataset{
+				Label:           yField,
+				Data:            yData,
+				BackgroundColor: bgColor,
+				BorderColor:     borderColor,
+				Fill:            cfg.Type == "area",
+			}
+			
+			if useRightAxis {
+				dataset.YAxisID = "y1"
+			} else {
+				dataset.YAxisID = "y"
 			}
+			
+			datasets = append(datasets, dataset)
 		}
 		
 		// Log clause processing if verbose mode is enabled
 		if !cfg.Quiet {
 			log.Printf("Processed clause %d: %d Y fields, right axis: %v", 
-				i+1, len(chartData.Datasets), useRightAxis)
+				i+1, len(datasets), useRightAxis)
 		}
 	}
 	
-	// Generate Chart.js configuration
-	err = cfg.generateChart(chartData)
-	if err != nil {
-		return fmt.Errorf("generating chart: %w", err)
-	}
-	
-	return nil
+	return datasets, nil
 }
 
 // generateChart outputs the HTML with Chart.js
@@ -492,7 +425,11 @@ func formatDatasets(datasets []Dataset) string {
 	for i, ds := range datasets {
 		dataValues := make([]string, len(ds.Data))
 		for j, val := range ds.Data {
-			dataValues[j] = fmt.Sprintf("%.2f", val)
+			if math.IsNaN(val) {
+				dataValues[j] = "null" // Chart.js leaves a gap
+			} else {
+				dataValues[j] = fmt.Sprintf("%.2f", val)
+			}
 		}
 		
 		yAxisPart := ""
@@ -545,6 +482,28 @@ func formatOptions(options ChartOptions) string {
 		options.Plugins["title"].Display, options.Plugins["title"].Text)
 }
 
+// Documentation implements the gs.Documenter interface
+func (cfg *ChartConfig) Documentation() gs.Documentation {
+	return gs.Documentation{
+		Name:    "tsv2chart",
+		Summary: "render TSV data as an interactive Chart.js chart",
+		Description: `Reads a TSV or CSV file, or standard input, and writes an HTML page
+containing a Chart.js chart to standard output.
+
+Each clause adds one dataset per -y field, filtered by the clause's -match conditions.`,
+		Examples: []gs.Example{
+			{
+				Command:     "tsv2chart data.tsv -x time -y cpu_usage -y memory_usage",
+				Description: "Chart two fields against time:",
+			},
+			{
+				Command:     "tsv2chart data.tsv -x time -y cpu_usage - -y disk_io -right",
+				Description: "Put disk_io on a right-hand scale in a second clause:",
+			},
+		},
+	}
+}
+
 // Validate implements the Commander interface
 func (cfg *ChartConfig) Validate() error {
 	// Enum validation now handled during parsing
@@ -559,6 +518,9 @@ func main() {
 		log.Fatalf("Failed to create command: %v", err)
 	}
 	
+	// Report unknown -x, -y and -match fields before reading the data
+	cmd.SetValidateFields(true)
+	
 	// Execute the command
 	if err := cmd.Execute(context.Background(), os.Args[1:]); err != nil {
 		log.Fatalf("Command failed: %v", err)
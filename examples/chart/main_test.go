@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rosscartlidge/gogstools/gs"
+)
+
+// chartDatasets parses args as tsv2chart would and returns the datasets
+// plotted from the input file, args[0]
+func chartDatasets(t *testing.T, args ...string) []Dataset {
+	t.Helper()
+	cfg := &ChartConfig{}
+	cmd, err := gs.NewCommand(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	cmd.SetConfigDir("")
+	clauses, err := cmd.Parse(args)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	data, err := parseTSV(context.Background(), args[0])
+	if err != nil {
+		t.Fatalf("Reading sample data failed: %v", err)
+	}
+	datasets, err := cfg.datasets(data, clauses, args[0])
+	if err != nil {
+		t.Fatalf("Building datasets failed: %v", err)
+	}
+	return datasets
+}
+
+func TestClauseSeries(t *testing.T) {
+	// A + clause without predicates plots every row on the right axis
+	datasets := chartDatasets(t, "testdata/sample.tsv", "-x", "time", "-y", "cpu_usage", "+", "-y", "disk_io", "-right")
+	if len(datasets) != 2 {
+		t.Fatalf("Expected 2 datasets, got %+v", datasets)
+	}
+	if !reflect.DeepEqual(datasets[1].Data, []float64{100, 120, 140, 110, 160}) || datasets[1].YAxisID != "y1" {
+		t.Errorf("Expected every disk_io value on the right axis, got %+v", datasets[1])
+	}
+
+	// Predicates still select the rows of their own clause
+	datasets = chartDatasets(t, "testdata/sample.tsv", "-x", "time", "-y", "cpu_usage", "-", "-y", "disk_io", "-gt", "disk_io", "130")
+	if !reflect.DeepEqual(datasets[1].Data, []float64{140, 160}) {
+		t.Errorf("Expected disk_io above 130, got %v", datasets[1].Data)
+	}
+}
+
+func TestNegatedY(t *testing.T) {
+	datasets := chartDatasets(t, "testdata/sample.tsv", "-x", "time", "-y", "memory_usage", "-y", "disk_io", "+y", "cpu_usage")
+	var labels []string
+	for _, dataset := range datasets {
+		labels = append(labels, dataset.Label)
+	}
+	if !reflect.DeepEqual(labels, []string{"memory_usage", "disk_io"}) {
+		t.Errorf("Expected +y cpu_usage to be excluded, got %v", labels)
+	}
+}
+
+func TestNonNumericValues(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "gaps.tsv")
+	if err := os.WriteFile(input, []byte("time\tcpu\n1\t25\n2\tn/a\n3\t35\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	datasets := chartDatasets(t, input, "-x", "time", "-y", "cpu")
+	if len(datasets) != 1 || len(datasets[0].Data) != 3 || !math.IsNaN(datasets[0].Data[1]) {
+		t.Fatalf("Expected a gap for the non-numeric value, got %+v", datasets)
+	}
+	if formatted := formatDatasets(datasets); !strings.Contains(formatted, `"data": [25.00, null, 35.00]`) {
+		t.Errorf("Expected null for the gap, got %s", formatted)
+	}
+}
@@ -1,3 +1,10 @@
 module github.com/rosscartlidge/gogstools
 
 go 1.24.4
+
+require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/term v0.36.0
+)
+
+require golang.org/x/sys v0.37.0 // indirect
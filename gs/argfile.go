@@ -0,0 +1,136 @@
+package gs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxExpansions bounds the response files and presets expanded for one
+// command line, so that one that includes itself is reported rather than
+// looping
+const maxExpansions = 100
+
+// isResponseFile reports whether arg names a response file, @path
+func isResponseFile(arg string) bool {
+	return len(arg) > 1 && arg[0] == '@'
+}
+
+// readResponseFile reads the arguments held in a response file
+func readResponseFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	args, err := splitArguments(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+	return args, nil
+}
+
+// expandResponseFiles splices the arguments of readable response files into
+// args, so completion sees the input file named in one. Unlike Parse it
+// ignores files it cannot read.
+func expandResponseFiles(args []string) []string {
+	var expanded []string
+	files := 0
+	for len(args) > 0 {
+		arg := args[0]
+		args = args[1:]
+		if isResponseFile(arg) && files < maxExpansions {
+			if inner, err := readResponseFile(arg[1:]); err == nil {
+				files++
+				args = append(inner, args...)
+				continue
+			}
+		}
+		expanded = append(expanded, arg)
+	}
+	return expanded
+}
+
+// splitArguments splits the text of a response file into arguments the way a
+// shell splits a command line. Arguments are separated by whitespace,
+// including newlines, so a clause and its separators may span several lines.
+// Single quotes keep text literally, double quotes and backslashes escape as
+// in the shell, a backslash before a newline continues the line and a word
+// starting with # comments out the rest of its line.
+func splitArguments(text string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	line := 1
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\n':
+			line++
+			fallthrough
+		case c == ' ' || c == '\t' || c == '\r':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '#' && !inWord:
+			for i+1 < len(text) && text[i+1] != '\n' {
+				i++
+			}
+		case c == '\\':
+			i++
+			switch {
+			case i >= len(text):
+			case text[i] == '\n':
+				line++
+			case text[i] == '\r' && i+1 < len(text) && text[i+1] == '\n':
+				i++
+				line++
+			default:
+				inWord = true
+				word.WriteByte(text[i])
+			}
+		case c == '\'':
+			end := strings.IndexByte(text[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%d: unterminated single quote", line)
+			}
+			inWord = true
+			quoted := text[i+1 : i+1+end]
+			word.WriteString(quoted)
+			line += strings.Count(quoted, "\n")
+			i += end + 1
+		case c == '"':
+			start := line
+			inWord = true
+			for i++; ; i++ {
+				if i >= len(text) {
+					return nil, fmt.Errorf("%d: unterminated double quote", start)
+				}
+				if text[i] == '"' {
+					break
+				}
+				if text[i] == '\n' {
+					line++
+				}
+				if text[i] == '\\' && i+1 < len(text) && strings.IndexByte("\\\"$`\n", text[i+1]) >= 0 {
+					i++
+					if text[i] == '\n' {
+						line++
+						continue
+					}
+				}
+				word.WriteByte(text[i])
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,510 @@
+package gs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType  = reflect.TypeOf(time.Duration(0))
+	negatableType = reflect.TypeOf((*negatableValue)(nil)).Elem()
+)
+
+// applyToConfig binds parsed values onto the config struct using reflection.
+// Global fields come from the global values; each local field is taken from
+// the first clause that has a value for it, so single-clause commands can read
+// everything from the struct. A value given with a + prefix is bound without
+// its negation unless the field is a Negatable.
+func (cmd *GSCommand) applyToConfig(global map[string]interface{}, clauses []ClauseSet) error {
+	configValue := reflect.ValueOf(cmd.config)
+	if configValue.Kind() == reflect.Ptr {
+		configValue = configValue.Elem()
+	}
+
+	var errs []error
+	for i := range cmd.fields {
+		meta := &cmd.fields[i]
+		field := configValue.FieldByName(meta.Name)
+		if !field.IsValid() || !field.CanSet() {
+			continue
+		}
+
+		value, ok := global[meta.Name]
+		if !ok || meta.Scope == ScopeLocal {
+			// Locals, and globals set positionally (such as a bare file argument),
+			// live in the clause fields
+			value, ok = firstClauseValue(clauses, meta.Name)
+		}
+		if !ok {
+			continue
+		}
+
+		if err := bindValue(field, value, meta); err != nil {
+			errs = append(errs, ParseError{Field: parseFlagName(meta.Name), Value: fmt.Sprint(value), Message: err.Error()})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// firstClauseValue returns the value of a field from the first clause that has it
+func firstClauseValue(clauses []ClauseSet, name string) (interface{}, bool) {
+	for _, clause := range clauses {
+		if value, ok := clause.Fields[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Negatable holds a switch value together with whether it was given with a
+// + prefix, e.g. `+y cpu_usage` decodes to Negatable[string]{"cpu_usage", true}
+type Negatable[T any] struct {
+	Value   T
+	Negated bool
+}
+
+// negatableValue is implemented by *Negatable[T] so bindValue can fill it
+// without knowing T
+type negatableValue interface {
+	negatableTarget() (value reflect.Value, negated *bool)
+}
+
+func (n *Negatable[T]) negatableTarget() (reflect.Value, *bool) {
+	return reflect.ValueOf(&n.Value).Elem(), &n.Negated
+}
+
+// Clauses decodes every clause into a new T, which must be a struct type.
+// A bool field named IsNegated receives ClauseSet.IsNegated.
+func Clauses[T any](clauses []ClauseSet) ([]T, error) {
+	decoded := make([]T, len(clauses))
+	for i, clause := range clauses {
+		if err := clause.Decode(&decoded[i]); err != nil {
+			return nil, fmt.Errorf("clause %d: %w", i+1, err)
+		}
+	}
+	return decoded, nil
+}
+
+// Decode fills the exported fields of the struct pointed to by dst from the
+// clause's parsed values, matching struct field names to config field names.
+// Values are converted to the destination types as for the config struct, and
+// a bool field named IsNegated receives the clause's negation.
+func (cs ClauseSet) Decode(dst interface{}) error {
+	target := reflect.ValueOf(dst)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode target must be a pointer to a struct, got %T", dst)
+	}
+	target = target.Elem()
+
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Field(i)
+		name := target.Type().Field(i).Name
+		if !field.CanSet() {
+			continue
+		}
+		if name == "IsNegated" && field.Kind() == reflect.Bool {
+			field.SetBool(cs.IsNegated)
+			continue
+		}
+		value, ok := cs.Fields[name]
+		if !ok {
+			continue
+		}
+		if err := bindValue(field, value, cs.fieldMeta(name)); err != nil {
+			return fmt.Errorf("decoding %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// fieldMeta returns the metadata for a named field, or nil if unknown
+func (cs ClauseSet) fieldMeta(name string) *FieldMeta {
+	for i := range cs.meta {
+		if cs.meta[i].Name == name {
+			return &cs.meta[i]
+		}
+	}
+	return nil
+}
+
+// bindValue converts a parsed value into dst. meta may be nil, in which case
+// multi-argument values are matched to struct fields and map keys by name.
+// Negation is recorded only by Negatable targets; anything else receives the
+// bare value.
+func bindValue(dst reflect.Value, value interface{}, meta *FieldMeta) error {
+	if dst.CanAddr() {
+		if target, ok := dst.Addr().Interface().(negatableValue); ok {
+			inner, negated := target.negatableTarget()
+			*negated = isNegated(value)
+			return bindValue(inner, value, meta)
+		}
+	}
+
+	value = unwrapNegated(value)
+	if value == nil {
+		return nil
+	}
+
+	if dst.Type() == durationType {
+		d, err := toDuration(value)
+		if err != nil {
+			return err
+		}
+		dst.SetInt(int64(d))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		v := reflect.ValueOf(value)
+		if !v.Type().AssignableTo(dst.Type()) {
+			return fmt.Errorf("cannot use %v as %s", value, dst.Type())
+		}
+		dst.Set(v)
+
+	case reflect.Ptr:
+		elem := reflect.New(dst.Type().Elem())
+		if err := bindValue(elem.Elem(), value, meta); err != nil {
+			return err
+		}
+		dst.Set(elem)
+
+	case reflect.String:
+		dst.SetString(toString(value))
+
+	case reflect.Bool:
+		b, err := toBool(value)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt(value)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toInt(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 || dst.OverflowUint(uint64(n)) {
+			return fmt.Errorf("value %d out of range for %s", n, dst.Type())
+		}
+		dst.SetUint(uint64(n))
+
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		dst.SetFloat(f)
+
+	case reflect.Slice:
+		items := toList(value)
+		slice := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		// Struct elements need the argument specs for positional binding, but
+		// key/value map semantics only apply to the field itself
+		var elemMeta *FieldMeta
+		if indirectType(dst.Type().Elem()).Kind() == reflect.Struct {
+			elemMeta = meta
+		}
+		for i, item := range items {
+			if err := bindValue(slice.Index(i), item, elemMeta); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		dst.Set(slice)
+
+	case reflect.Map:
+		if meta == nil || len(meta.Args) != 2 {
+			if v := reflect.ValueOf(value); v.Type().AssignableTo(dst.Type()) {
+				dst.Set(v)
+				return nil
+			}
+		}
+		return bindMap(dst, value, meta)
+
+	case reflect.Struct:
+		args, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot use %v as %s", value, dst.Type())
+		}
+		if meta != nil && len(meta.Args) > 0 {
+			return bindStructArgs(dst, args, meta.Args)
+		}
+		return bindStruct(dst, args)
+
+	default:
+		return fmt.Errorf("unsupported field type %s", dst.Type())
+	}
+
+	return nil
+}
+
+// bindMap fills a map from multi-argument values. A switch with two arguments
+// is treated as a key/value pair, so `-set name value` repeated builds a map;
+// otherwise the argument names become the keys.
+func bindMap(dst reflect.Value, value interface{}, meta *FieldMeta) error {
+	mapType := dst.Type()
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMap(mapType))
+	}
+
+	for _, item := range toList(value) {
+		args, ok := unwrapNegated(item).(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot use %v as %s", item, mapType)
+		}
+
+		if meta != nil && len(meta.Args) == 2 {
+			key := reflect.New(mapType.Key()).Elem()
+			if err := bindValue(key, args[meta.Args[0].Name], nil); err != nil {
+				return fmt.Errorf("map key: %w", err)
+			}
+			elem := reflect.New(mapType.Elem()).Elem()
+			if err := bindValue(elem, args[meta.Args[1].Name], nil); err != nil {
+				return fmt.Errorf("map value: %w", err)
+			}
+			dst.SetMapIndex(key, elem)
+			continue
+		}
+
+		for name, arg := range args {
+			if strings.HasPrefix(name, "_") {
+				continue // Internal markers such as _negated
+			}
+			key := reflect.New(mapType.Key()).Elem()
+			if err := bindValue(key, name, nil); err != nil {
+				return fmt.Errorf("map key: %w", err)
+			}
+			elem := reflect.New(mapType.Elem()).Elem()
+			if err := bindValue(elem, arg, nil); err != nil {
+				return fmt.Errorf("map value %s: %w", name, err)
+			}
+			dst.SetMapIndex(key, elem)
+		}
+	}
+
+	return nil
+}
+
+// bindStruct fills a struct from multi-argument values, matching argument
+// names to struct field names case-insensitively. A bool field named Negated
+// records whether the switch was given with a + prefix.
+func bindStruct(dst reflect.Value, args map[string]interface{}) error {
+	typ := dst.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := dst.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		if typ.Field(i).Name == "Negated" && field.Kind() == reflect.Bool {
+			field.SetBool(isNegated(args))
+			continue
+		}
+		for name, arg := range args {
+			if strings.EqualFold(name, typ.Field(i).Name) {
+				if err := bindValue(field, arg, nil); err != nil {
+					return fmt.Errorf("%s: %w", typ.Field(i).Name, err)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// bindStructArgs fills a struct from multi-argument values by position: the
+// Nth argument of the switch goes to the Nth exported field, skipping a bool
+// field named Negated, which records a + prefix
+func bindStructArgs(dst reflect.Value, args map[string]interface{}, specs []ArgumentSpec) error {
+	fields := argStructFields(dst.Type())
+	if len(fields) < len(specs) {
+		return fmt.Errorf("%s has %d fields for %d arguments", dst.Type(), len(fields), len(specs))
+	}
+
+	for i, spec := range specs {
+		field := dst.FieldByIndex(fields[i].Index)
+		if err := bindValue(field, args[spec.Name], nil); err != nil {
+			return fmt.Errorf("argument %s: %w", spec.Name, err)
+		}
+	}
+
+	if negated, ok := dst.Type().FieldByName("Negated"); ok && negated.Type.Kind() == reflect.Bool {
+		dst.FieldByIndex(negated.Index).SetBool(isNegated(args))
+	}
+
+	return nil
+}
+
+// argStructFields returns the exported fields of a multi-argument struct that
+// receive argument values, in declaration order
+func argStructFields(typ reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Name == "Negated" && field.Type.Kind() == reflect.Bool {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// validateArgStruct checks that a struct bound to a multi-argument switch,
+// directly or as a slice or pointer element, has a field for every argument
+// and that number arguments go to numeric or string fields
+func validateArgStruct(typ reflect.Type, specs []ArgumentSpec) error {
+	typ = indirectType(typ)
+	if typ.Kind() == reflect.Slice {
+		typ = indirectType(typ.Elem())
+	}
+	if reflect.PointerTo(typ).Implements(negatableType) {
+		typ = indirectType(typ.Field(0).Type) // Negatable[T].Value
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := argStructFields(typ)
+	if len(fields) < len(specs) {
+		return fmt.Errorf("%s has %d fields for %d arguments", typ, len(fields), len(specs))
+	}
+
+	for i, spec := range specs {
+		if spec.Type != ArgumentTypeNumber {
+			continue
+		}
+		switch indirectType(fields[i].Type).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64, reflect.String, reflect.Interface:
+		default:
+			return fmt.Errorf("number argument %s cannot be stored in %s.%s (%s)",
+				spec.Name, typ, fields[i].Name, fields[i].Type)
+		}
+	}
+
+	return nil
+}
+
+// indirectType strips pointer types
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// unwrapNegated strips the {"value": v, "_negated": true} wrapper used for
+// negated single-argument switches
+func unwrapNegated(value interface{}) interface{} {
+	if wrapped, ok := value.(map[string]interface{}); ok && len(wrapped) == 2 {
+		if _, negated := wrapped["_negated"]; negated {
+			if inner, ok := wrapped["value"]; ok {
+				return inner
+			}
+		}
+	}
+	return value
+}
+
+// isNegated reports whether a parsed value carries the _negated marker
+func isNegated(value interface{}) bool {
+	if args, ok := value.(map[string]interface{}); ok {
+		negated, _ := args["_negated"].(bool)
+		return negated
+	}
+	return false
+}
+
+// toList normalizes a parsed value into a list
+func toList(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return items
+	default:
+		return []interface{}{v}
+	}
+}
+
+func toString(value interface{}) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	default:
+		return false, fmt.Errorf("cannot use %v as bool", value)
+	}
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return 0, fmt.Errorf("cannot use %v as number", value)
+	}
+}
+
+func toInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v is not a whole number", v)
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("cannot use %v as integer", value)
+	}
+}
+
+// toDuration parses duration strings such as "1m30s"; plain numbers are seconds
+func toDuration(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case string:
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+		return time.ParseDuration(v)
+	default:
+		return 0, fmt.Errorf("cannot use %v as duration", value)
+	}
+}
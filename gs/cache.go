@@ -0,0 +1,247 @@
+package gs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rosscartlidge/gogstools/gs/tsv"
+)
+
+const (
+	// cacheMaxEntries bounds the number of files the completion cache holds;
+	// the least recently used entries are evicted beyond it
+	cacheMaxEntries = 256
+
+	// cacheStaleSize is the size from which a file that has only grown since
+	// it was cached, such as a log being appended to, is completed from the
+	// cache rather than rescanned. Appending leaves the header and the rows
+	// sampled from the start of the file unchanged.
+	cacheStaleSize = 1 << 20
+
+	// cachePrefixSize is how much of the start of a large file is hashed to
+	// check that it has only been appended to, not rewritten in place
+	cachePrefixSize = 64 << 10
+)
+
+// cacheEntry is the cached completion data of one input file. A file is
+// identified by path, and the entry is valid while its size, modification
+// time and inode are unchanged.
+type cacheEntry struct {
+	Path    string              `json:"path"`
+	Size    int64               `json:"size"`
+	ModTime int64               `json:"mtime"`            // Unix nanoseconds
+	Inode   uint64              `json:"inode"`            // 0 where unavailable
+	Prefix  string              `json:"prefix,omitempty"` // Hash of the start of a large file
+	Depth   int                 `json:"depth"`            // Scan depth Values were sampled with
+	Fields  []string            `json:"fields,omitempty"`
+	Values  map[string][]string `json:"values,omitempty"`
+	Kinds   map[string]tsv.Kind `json:"kinds,omitempty"`
+
+	appended bool // The file has been appended to since; only completion uses the entry
+}
+
+// SetCacheDir sets the directory for cached completion data and piped input
+// headers; "" disables caching. It defaults to gogstools under the user cache
+// directory ($XDG_CACHE_HOME or ~/.cache on Linux).
+func (cmd *GSCommand) SetCacheDir(dir string) {
+	cmd.cacheDir = dir
+}
+
+// cachePath returns the file holding the cache entry for an input file, or ""
+// if caching is disabled
+func (cmd *GSCommand) cachePath(filename string) string {
+	if cmd.cacheDir == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(cmd.cacheDir, "completion", hex.EncodeToString(sum[:16])+".json")
+}
+
+// cachedEntry returns the cache entry for filename if it is still valid, or
+// if appended is true and the file has only been appended to since
+func (cmd *GSCommand) cachedEntry(filename string, appended bool) *cacheEntry {
+	path := cmd.cachePath(filename)
+	if path == "" {
+		return nil
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if json.Unmarshal(data, &entry) != nil {
+		return nil
+	}
+	if !entry.validFor(filename, info) {
+		if !appended || !entry.appendedTo(filename, info) {
+			return nil
+		}
+		entry.appended = true
+	}
+
+	// Mark the entry as recently used for eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return &entry
+}
+
+// lookupCache returns the data stored under key in memory, or else the data
+// fromEntry finds in the on-disk cache entry for filename, remembering it in
+// memory. Each completion is a new process, so the disk cache is what carries
+// data from one TAB press to the next. appended accepts the entry of a large
+// file that has only been appended to since, which is good enough for
+// completion but not for validation; such data is not remembered.
+func lookupCache[T any](cmd *GSCommand, memory map[string]T, key, filename string, appended bool, fromEntry func(*cacheEntry) (T, bool)) (T, bool) {
+	if value, exists := memory[key]; exists {
+		return value, true
+	}
+	if entry := cmd.cachedEntry(filename, appended); entry != nil {
+		if value, exists := fromEntry(entry); exists {
+			if !entry.appended {
+				memory[key] = value
+			}
+			return value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// validFor reports whether the entry still describes the file
+func (e *cacheEntry) validFor(filename string, info os.FileInfo) bool {
+	if abs, err := filepath.Abs(filename); err != nil || abs != e.Path {
+		return false
+	}
+	return info.Size() == e.Size && info.ModTime().UnixNano() == e.ModTime && fileInode(info) == e.Inode
+}
+
+// appendedTo reports whether the entry describes a large file that has since
+// grown in place with its start unchanged, so that its header and sampled
+// values are still representative. A file rewritten in place, even with
+// more data, has a different start.
+func (e *cacheEntry) appendedTo(filename string, info os.FileInfo) bool {
+	if abs, err := filepath.Abs(filename); err != nil || abs != e.Path {
+		return false
+	}
+	if e.Inode == 0 || fileInode(info) != e.Inode || e.Size < cacheStaleSize || info.Size() <= e.Size || e.Prefix == "" {
+		return false
+	}
+	prefix, err := prefixHash(filename)
+	return err == nil && prefix == e.Prefix
+}
+
+// prefixHash returns a hash of the first cachePrefixSize bytes of a file
+func prefixHash(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.CopyN(hash, file, cachePrefixSize); err != nil && err != io.EOF {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// updateCache applies update to the cache entry for filename, starting afresh
+// if there is no valid entry, and saves it. The cache is locked from reading
+// the entry to saving it, so concurrent updates build on one another.
+func (cmd *GSCommand) updateCache(filename string, update func(*cacheEntry)) {
+	path := cmd.cachePath(filename)
+	if path == "" {
+		return
+	}
+	unlock, err := lockCache(filepath.Dir(path))
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	// Data sampled now describes the file as it is, so an entry for it before
+	// it was appended to is replaced
+	entry := cmd.cachedEntry(filename, false)
+	if entry == nil {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return
+		}
+		abs, _ := filepath.Abs(filename)
+		entry = &cacheEntry{
+			Path:    abs,
+			Size:    info.Size(),
+			ModTime: info.ModTime().UnixNano(),
+			Inode:   fileInode(info),
+		}
+		if entry.Size >= cacheStaleSize {
+			entry.Prefix, _ = prefixHash(filename)
+		}
+	}
+	update(entry)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if writeFileAtomic(path, data) == nil {
+		evictCache(filepath.Dir(path), cacheMaxEntries)
+	}
+}
+
+// writeFileAtomic replaces path with data through a temporary file, so that
+// concurrent readers, such as completion in another shell, never see a
+// partial file
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// evictCache removes the least recently used entries beyond limit
+func evictCache(dir string, limit int) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(paths) <= limit {
+		return
+	}
+
+	used := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			used[path] = info.ModTime()
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return used[paths[i]].Before(used[paths[j]]) })
+
+	for _, path := range paths[:len(paths)-limit] {
+		os.Remove(path) // Another shell may have removed it already
+	}
+}
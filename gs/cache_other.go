@@ -0,0 +1,17 @@
+//go:build !unix
+
+package gs
+
+import "os"
+
+// fileInode returns 0 where inode numbers are unavailable; entries are then
+// validated by size and modification time alone
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
+
+// lockCache does nothing where flock is unavailable; entries are still
+// replaced atomically, but concurrent updates may overwrite one another
+func lockCache(dir string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package gs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// fileInode returns the inode number of a file, so that a file replaced by
+// another of the same size and time is not mistaken for the cached one
+func fileInode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
+
+// lockCache takes an exclusive lock on the cache directory dir, so that shells
+// updating entries at the same time don't lose one another's changes. The
+// returned function releases it.
+func lockCache(dir string) (func(), error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+	}, nil
+}
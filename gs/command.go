@@ -1,14 +1,18 @@
 package gs
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/rosscartlidge/gogstools/gs/tsv"
 )
 
 // GSCommand represents a command with GS-style argument processing
@@ -17,10 +21,20 @@ type GSCommand struct {
 	fields      []FieldMeta // Metadata for all fields
 	completer   Completer   // Completion handler
 	generator   DocumentGenerator // Documentation generator
-	fieldCache  map[string][]string // TSV field name cache
+	fieldCache  map[string][]string // TSV field name cache, backed by the on-disk cache
 	contentCache map[string]map[string][]string // TSV content cache: filename -> field -> values
-	scanDepth   int // Number of lines to scan for content completion
+	kindCache   map[string]map[string]tsv.Kind // Column kind cache: filename -> field -> kind
+	scanDepth   int // Number of values to sample for content completion
+	scanBudget  time.Duration // Time content completion may spend reading a file
 	commandName string // Name of the command binary for completion scripts
+	validateFields bool // Check field names against the input header during Parse
+	stdin       io.Reader // Standard input, replaying any header already read
+	stdinPeek   *stdinPeek // Header and rows read ahead from stdin
+	cacheDir    string // Directory for cached input headers; "" disables caching
+	configDir   string // Directory for per-command config files; "" disables them
+	group       *Group // Group the command is a subcommand of, if any
+	groupNamed  bool // commandName was set by a Group and overrides Documentation
+	configID    string // Group and subcommand name keying config, env and sidecar names, e.g. "tsv-chart"
 }
 
 // NewCommand creates a new GSCommand from a configuration struct
@@ -36,13 +50,30 @@ func NewCommand(config interface{}) (*GSCommand, error) {
 		commandName = filepath.Base(os.Args[0])
 	}
 	
+	// Cache input headers so completion works for piped input
+	cacheDir := ""
+	if dir, err := os.UserCacheDir(); err == nil {
+		cacheDir = filepath.Join(dir, "gogstools")
+	}
+	
+	// Per-command defaults live alongside other user configuration
+	configDir := ""
+	if dir, err := os.UserConfigDir(); err == nil {
+		configDir = filepath.Join(dir, "gogstools")
+	}
+	
 	cmd := &GSCommand{
 		config:       config,
 		fields:       fields,
 		fieldCache:   make(map[string][]string),
 		contentCache: make(map[string]map[string][]string),
+		kindCache:    make(map[string]map[string]tsv.Kind),
 		scanDepth:    100, // Default scan depth like TSVSelect
+		scanBudget:   defaultScanBudget,
 		commandName:  commandName,
+		stdin:        os.Stdin,
+		cacheDir:     cacheDir,
+		configDir:    configDir,
 	}
 	
 	return cmd, nil
@@ -55,7 +86,15 @@ func (cmd *GSCommand) Parse(args []string) ([]ClauseSet, error) {
 		Fields: make(map[string]interface{}),
 	}
 	global := make(map[string]interface{}) // Track global fields separately
+	var errs []error                        // Every problem found, reported together
+	var refs []fieldRef                     // Field names to check against the input header
 	
+	// Config files are checked up front, invalid defaults only when used
+	defaults, defaultErrs := cmd.layeredDefaults()
+	errs = append(errs, defaultErrs...)
+	
+	var presets map[string]preset // Read when a preset is first used
+	expansions := 0               // Response files and presets expanded so far
 	i := 0
 	for i < len(args) {
 		arg := args[i]
@@ -78,6 +117,32 @@ func (cmd *GSCommand) Parse(args []string) ([]ClauseSet, error) {
 			}
 			i++
 			
+		case isPresetRef(arg) || (arg == presetFlag && cmd.usesPresetFlag()):
+			// Splice in the arguments of a preset from a config file
+			name, consumed := strings.TrimPrefix(arg, "@@"), 1
+			if arg == presetFlag {
+				if i+1 >= len(args) {
+					errs = append(errs, ParseError{Field: presetFlag, Message: "requires a preset name", Position: i + 1})
+					i++
+					continue
+				}
+				name, consumed = args[i+1], 2
+			}
+			if presets == nil {
+				presets = cmd.presets()
+			}
+			expansions++
+			expanded, err := expandPreset(presets, name)
+			if err == nil && expansions > maxExpansions {
+				err = fmt.Errorf("more than %d response files and presets; does preset %s include itself?", maxExpansions, name)
+			}
+			if err != nil {
+				errs = append(errs, ParseError{Value: name, Message: err.Error(), Position: i + consumed})
+				i += consumed
+			} else {
+				args = slices.Concat(args[:i], expanded, args[i+consumed:])
+			}
+			
 		case strings.HasPrefix(arg, "+"):
 			// Handle +flag syntax (negated flag within current clause)
 			if len(arg) > 1 {
@@ -85,7 +150,9 @@ func (cmd *GSCommand) Parse(args []string) ([]ClauseSet, error) {
 				flagArg := "-" + arg[1:] // Convert +flag to -flag
 				consumed, err := cmd.parseFlagWithNegation(append([]string{flagArg}, args[i+1:]...), &current, global, true)
 				if err != nil {
-					return nil, err
+					errs = append(errs, offsetPosition(err, i))
+				} else if cmd.validateFields {
+					refs = append(refs, cmd.fieldRefs(args[i:i+consumed], i)...)
 				}
 				i += consumed
 			} else {
@@ -104,7 +171,9 @@ func (cmd *GSCommand) Parse(args []string) ([]ClauseSet, error) {
 				// Regular -flag (positive)
 				consumed, err := cmd.parseFlagWithNegation(args[i:], &current, global, false)
 				if err != nil {
-					return nil, err
+					errs = append(errs, offsetPosition(err, i))
+				} else if cmd.validateFields {
+					refs = append(refs, cmd.fieldRefs(args[i:i+consumed], i)...)
 				}
 				i += consumed
 			} else {
@@ -117,13 +186,27 @@ func (cmd *GSCommand) Parse(args []string) ([]ClauseSet, error) {
 				i++
 			}
 			
+		case isResponseFile(arg):
+			// Splice in the arguments of a response file, which may name others
+			expansions++
+			expanded, err := readResponseFile(arg[1:])
+			if err == nil && expansions > maxExpansions {
+				err = fmt.Errorf("more than %d response files and presets; does %s include itself?", maxExpansions, arg)
+			}
+			if err != nil {
+				errs = append(errs, ParseError{Value: arg, Message: err.Error(), Position: i + 1})
+				i++
+			} else {
+				args = slices.Concat(args[:i], expanded, args[i+1:])
+			}
+			
 		default:
 			// Positional argument (likely filename)
 			current.Fields["_args"] = append(
 				getStringSlice(current.Fields["_args"]), arg)
 			
 			// If this looks like a TSV file and no -argv has been set, treat as file input
-			if strings.HasSuffix(strings.ToLower(arg), ".tsv") || strings.HasSuffix(strings.ToLower(arg), ".csv") {
+			if tsv.IsDataFile(arg) {
 				if _, hasArgv := current.Fields["Argv"]; !hasArgv {
 					// Also check global fields to avoid overriding explicit -argv
 					if _, hasGlobalArgv := global["Argv"]; !hasGlobalArgv {
@@ -138,6 +221,10 @@ func (cmd *GSCommand) Parse(args []string) ([]ClauseSet, error) {
 	// Add final clause
 	clauses = append(clauses, current)
 	
+	for i := range clauses {
+		clauses[i].meta = cmd.fields
+	}
+	
 	// Apply global fields to all clauses
 	for i := range clauses {
 		for k, v := range global {
@@ -147,34 +234,63 @@ func (cmd *GSCommand) Parse(args []string) ([]ClauseSet, error) {
 		}
 	}
 	
-	// Apply defaults and validate
-	if err := cmd.applyDefaults(clauses); err != nil {
-		return nil, err
-	}
+	// Apply defaults, reporting invalid ones the command line left in use
+	errs = append(errs, cmd.applyDefaults(clauses, defaults)...)
 	
 	// Apply defaults to global fields too
 	for _, fieldMeta := range cmd.fields {
-		if fieldMeta.Scope == ScopeGlobal && fieldMeta.DefaultValue != nil {
+		if def, ok := defaults[fieldMeta.Name]; ok && def.err == nil && fieldMeta.Scope == ScopeGlobal {
 			if _, exists := global[fieldMeta.Name]; !exists {
-				global[fieldMeta.Name] = fieldMeta.DefaultValue
+				global[fieldMeta.Name] = def.value
 			}
 		}
 	}
 	
-	// Also apply global values to the config struct
-	if err := cmd.applyGlobalToConfig(global); err != nil {
-		return nil, err
+	errs = append(errs, flattenErrors(cmd.checkRequired(global, clauses))...)
+	if len(refs) > 0 {
+		errs = append(errs, cmd.checkFieldRefs(args, refs, readsStdin(clauses))...)
+	}
+	if len(errs) > 0 {
+		return nil, ArgumentErrors{Args: args, Errors: errs}
+	}
+	
+	// Bind parsed values onto the config struct
+	if err := cmd.applyToConfig(global, clauses); err != nil {
+		return nil, ArgumentErrors{Args: args, Errors: flattenErrors(err)}
 	}
 	
 	return clauses, nil
 }
 
+// offsetPosition shifts the position of a ParseError returned for args[offset:]
+// so that it refers to the full argument list
+func offsetPosition(err error, offset int) error {
+	if parseErr, ok := err.(ParseError); ok && parseErr.Position > 0 {
+		parseErr.Position += offset
+		return parseErr
+	}
+	return err
+}
+
+// flattenErrors expands joined errors into a list
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
 // parseFlag parses a single flag and its value(s)
 func (cmd *GSCommand) parseFlag(args []string, clause *ClauseSet, global map[string]interface{}) (int, error) {
 	return cmd.parseFlagWithNegation(args, clause, global, false)
 }
 
-// parseFlagWithNegation parses a single flag and its value(s) with optional negation
+// parseFlagWithNegation parses a single flag and its value(s) with optional negation.
+// It always reports how many arguments to skip, even on error, so that parsing
+// can continue; errors are ParseErrors positioned relative to args.
 func (cmd *GSCommand) parseFlagWithNegation(args []string, clause *ClauseSet, global map[string]interface{}, negated bool) (int, error) {
 	if len(args) == 0 {
 		return 0, fmt.Errorf("no arguments to parse")
@@ -193,7 +309,7 @@ func (cmd *GSCommand) parseFlagWithNegation(args []string, clause *ClauseSet, gl
 	}
 	
 	if fieldMeta == nil {
-		return 0, fmt.Errorf("unknown flag: %s", flagName)
+		return 1, ParseError{Value: flagName, Message: cmd.unknownFlagMessage(flagName, negated), Position: 1}
 	}
 	
 	// Determine where to store the value based on scope
@@ -215,12 +331,16 @@ func (cmd *GSCommand) parseFlagWithNegation(args []string, clause *ClauseSet, gl
 	case FieldTypeMulti:
 		// Multi-argument switch
 		if len(fieldMeta.Args) == 0 {
-			return 0, fmt.Errorf("multi-argument flag %s has no argument specification", flagName)
+			return 1, ParseError{Field: flagName, Message: "multi-argument flag has no argument specification", Position: 1}
 		}
 		
 		requiredArgs := len(fieldMeta.Args)
 		if len(args) < requiredArgs+1 {
-			return 0, fmt.Errorf("flag %s requires %d arguments", flagName, requiredArgs)
+			return len(args), ParseError{
+				Field:    flagName,
+				Message:  fmt.Sprintf("requires %d arguments: %s", requiredArgs, strings.Join(argumentPlaceholders(*fieldMeta), " ")),
+				Position: 1,
+			}
 		}
 		
 		// Parse each argument according to its specification
@@ -229,7 +349,12 @@ func (cmd *GSCommand) parseFlagWithNegation(args []string, clause *ClauseSet, gl
 			argValue := args[i+1]
 			parsedValue, err := cmd.parseValueByArgumentType(argValue, argSpec.Type)
 			if err != nil {
-				return 0, fmt.Errorf("parsing argument %s for %s: %w", argSpec.Name, flagName, err)
+				return requiredArgs + 1, ParseError{
+					Field:    flagName,
+					Value:    argValue,
+					Message:  fmt.Sprintf("argument %s: %v", argSpec.Name, err),
+					Position: i + 2,
+				}
 			}
 			argValues[argSpec.Name] = parsedValue
 		}
@@ -260,13 +385,13 @@ func (cmd *GSCommand) parseFlagWithNegation(args []string, clause *ClauseSet, gl
 	default:
 		// Single-argument flag
 		if len(args) < 2 {
-			return 0, fmt.Errorf("flag %s requires a value", flagName)
+			return 1, ParseError{Field: flagName, Message: "requires a value", Position: 1}
 		}
 		
 		value := args[1]
 		parsedValue, err := cmd.parseValueWithValidation(value, fieldMeta)
 		if err != nil {
-			return 0, fmt.Errorf("parsing value for %s: %w", flagName, err)
+			return 2, ParseError{Field: flagName, Value: value, Message: err.Error(), Position: 2}
 		}
 		
 		// For single-argument switches, wrap in map if negated
@@ -298,13 +423,31 @@ func (cmd *GSCommand) parseFlagWithNegation(args []string, clause *ClauseSet, gl
 	}
 }
 
+// unknownFlagMessage describes an unknown flag, suggesting the closest known one
+func (cmd *GSCommand) unknownFlagMessage(flagName string, negated bool) string {
+	prefix := "-"
+	if negated {
+		prefix = "+"
+	}
+	given := prefix + flagName[1:]
+	
+	names := make([]string, len(cmd.fields))
+	for i, field := range cmd.fields {
+		names[i] = parseFlagName(field.Name)[1:]
+	}
+	if suggestion := Suggest(flagName[1:], names); suggestion != "" {
+		return fmt.Sprintf("unknown flag: %s; did you mean %s%s?", given, prefix, suggestion)
+	}
+	return "unknown flag: " + given
+}
+
 // parseValue converts a string value to the appropriate type
 func (cmd *GSCommand) parseValue(value string, fieldType FieldType) (interface{}, error) {
 	switch fieldType {
 	case FieldTypeString, FieldTypeField, FieldTypeFile:
 		return value, nil
 	case FieldTypeNumber:
-		return strconv.ParseFloat(value, 64)
+		return parseNumber(value)
 	case FieldTypeFlag:
 		return strconv.ParseBool(value)
 	default:
@@ -312,6 +455,15 @@ func (cmd *GSCommand) parseValue(value string, fieldType FieldType) (interface{}
 	}
 }
 
+// parseNumber parses a numeric argument with a user-facing error
+func parseNumber(value string) (float64, error) {
+	num, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s'", value)
+	}
+	return num, nil
+}
+
 // parseValueWithValidation converts a string value to the appropriate type and validates enum constraints
 func (cmd *GSCommand) parseValueWithValidation(value string, fieldMeta *FieldMeta) (interface{}, error) {
 	// First parse the value according to its type
@@ -331,8 +483,12 @@ func (cmd *GSCommand) parseValueWithValidation(value string, fieldMeta *FieldMet
 			}
 		}
 		if !found {
-			return nil, fmt.Errorf("invalid value '%s', must be one of: %s", 
+			msg := fmt.Sprintf("invalid value '%s', must be one of: %s", 
 				value, strings.Join(fieldMeta.Enum, ", "))
+			if suggestion := Suggest(value, fieldMeta.Enum); suggestion != "" {
+				msg += fmt.Sprintf("; did you mean '%s'?", suggestion)
+			}
+			return nil, fmt.Errorf("%s", msg)
 		}
 	}
 	
@@ -345,56 +501,83 @@ func (cmd *GSCommand) parseValueByArgumentType(value string, argType ArgumentTyp
 	case ArgumentTypeString, ArgumentTypeField, ArgumentTypeContent, ArgumentTypeFile:
 		return value, nil
 	case ArgumentTypeNumber:
-		return strconv.ParseFloat(value, 64)
+		return parseNumber(value)
 	default:
 		return value, nil
 	}
 }
 
-// applyDefaults applies default values to fields that weren't specified
-func (cmd *GSCommand) applyDefaults(clauses []ClauseSet) error {
-	for i := range clauses {
-		for _, fieldMeta := range cmd.fields {
-			if _, exists := clauses[i].Fields[fieldMeta.Name]; !exists {
-				if fieldMeta.DefaultValue != nil {
-					clauses[i].Fields[fieldMeta.Name] = fieldMeta.DefaultValue
-				}
+// checkRequired reports every required field that was not given, with the
+// clause number for missing clause-local fields
+func (cmd *GSCommand) checkRequired(global map[string]interface{}, clauses []ClauseSet) error {
+	var errs []error
+	for _, fieldMeta := range cmd.fields {
+		if !fieldMeta.Required {
+			continue
+		}
+		flag := parseFlagName(fieldMeta.Name)
+		
+		if fieldMeta.Scope == ScopeGlobal {
+			if _, exists := global[fieldMeta.Name]; exists {
+				continue
+			}
+			// Globals such as Argv may also be set by a bare argument in any clause
+			if _, exists := firstClauseValue(clauses, fieldMeta.Name); exists {
+				continue
+			}
+			errs = append(errs, ValidationError{Field: flag, Message: "required flag not given"})
+			continue
+		}
+		
+		for i, clause := range clauses {
+			if _, exists := clause.Fields[fieldMeta.Name]; !exists {
+				errs = append(errs, ValidationError{Field: flag, Clause: i + 1, Message: "required flag not given"})
 			}
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
-// applyGlobalToConfig applies global field values to the config struct using reflection
-func (cmd *GSCommand) applyGlobalToConfig(global map[string]interface{}) error {
-	if len(global) == 0 {
-		return nil
-	}
-	
-	configValue := reflect.ValueOf(cmd.config)
-	if configValue.Kind() == reflect.Ptr {
-		configValue = configValue.Elem()
+// orderedFields returns the field metadata with required fields first,
+// otherwise in declaration order
+func (cmd *GSCommand) orderedFields() []FieldMeta {
+	ordered := make([]FieldMeta, 0, len(cmd.fields))
+	for _, field := range cmd.fields {
+		if field.Required {
+			ordered = append(ordered, field)
+		}
 	}
-	
-	for fieldName, value := range global {
-		field := configValue.FieldByName(fieldName)
-		if !field.IsValid() || !field.CanSet() {
-			continue
+	for _, field := range cmd.fields {
+		if !field.Required {
+			ordered = append(ordered, field)
 		}
-		
-		valueReflect := reflect.ValueOf(value)
-		if field.Type().AssignableTo(valueReflect.Type()) {
-			field.Set(valueReflect)
-		} else if field.Kind() == reflect.String && valueReflect.Kind() == reflect.String {
-			field.SetString(valueReflect.String())
-		} else if field.Kind() == reflect.Float64 && valueReflect.Kind() == reflect.Float64 {
-			field.SetFloat(valueReflect.Float())
-		} else if field.Kind() == reflect.Bool && valueReflect.Kind() == reflect.Bool {
-			field.SetBool(valueReflect.Bool())
+	}
+	return ordered
+}
+
+// applyDefaults applies default values to fields that weren't specified,
+// returning the errors of invalid defaults that would have been applied
+func (cmd *GSCommand) applyDefaults(clauses []ClauseSet, defaults map[string]layeredDefault) []error {
+	var errs []error
+	reported := make(map[string]bool)
+	for i := range clauses {
+		for _, fieldMeta := range cmd.fields {
+			if _, exists := clauses[i].Fields[fieldMeta.Name]; !exists {
+				def, ok := defaults[fieldMeta.Name]
+				switch {
+				case !ok:
+				case def.err != nil:
+					if !reported[fieldMeta.Name] {
+						reported[fieldMeta.Name] = true
+						errs = append(errs, def.err)
+					}
+				default:
+					clauses[i].Fields[fieldMeta.Name] = def.value
+				}
+			}
 		}
 	}
-	
-	return nil
+	return errs
 }
 
 // Execute runs the command with the given arguments
@@ -408,11 +591,17 @@ func (cmd *GSCommand) Execute(ctx context.Context, args []string) error {
 		case "-man":
 			fmt.Println(cmd.GenerateManPage())
 			return nil
-		case "-complete":
+		case "-complete", "-complete-desc", "-complete-v1", "-complete-bash":
 			return cmd.handleCompletion(args)
 		case "-bash-completion":
 			fmt.Print(cmd.generateBashCompletion())
 			return nil
+		case "-zsh-completion":
+			fmt.Print(cmd.generateZshCompletion())
+			return nil
+		case "-fish-completion":
+			fmt.Print(cmd.generateFishCompletion())
+			return nil
 		}
 	}
 	
@@ -428,14 +617,27 @@ func (cmd *GSCommand) Execute(ctx context.Context, args []string) error {
 			return fmt.Errorf("validation failed: %w", err)
 		}
 		
-		return commander.Execute(ctx, clauses)
+		return commander.Execute(withCommand(ctx, cmd), clauses)
 	}
 	
 	return fmt.Errorf("command does not implement Commander interface")
 }
 
-// handleCompletion handles bash completion
+// handleCompletion handles shell completion requests
 func (cmd *GSCommand) handleCompletion(args []string) error {
+	return writeCompletions(os.Stdout, args, cmd.completeDescribed)
+}
+
+// describeFunc returns the completion candidates for the argument at pos
+type describeFunc func(args []string, pos int) ([]Candidate, error)
+
+// writeCompletions answers a completion request with candidates from describe.
+// -complete prints one candidate per line; -complete-desc prints
+// "candidate<TAB>description" lines for shells that can show descriptions;
+// -complete-v1 prints the versioned structured form read by the generated
+// scripts (see writeCandidates); -complete-bash takes bash's COMP_POINT and
+// COMP_LINE instead of words and prints the same form with escaped values.
+func writeCompletions(w io.Writer, args []string, describe describeFunc) error {
 	if len(args) < 3 {
 		return fmt.Errorf("completion requires position and arguments")
 	}
@@ -449,15 +651,30 @@ func (cmd *GSCommand) handleCompletion(args []string) error {
 	
 	// pos is the position in the user's command line, use it as-is for compArgs
 	// No adjustment needed - position semantics should be consistent
+	if args[0] == "-complete-bash" {
+		return writeBashCandidates(w, compArgs[0], pos, describe)
+	}
 	
-	// Use integrated completion logic
-	completions, err := cmd.complete(compArgs, pos)
+	candidates, err := describe(compArgs, pos)
 	if err != nil {
 		return err
 	}
 	
-	for _, completion := range completions {
-		fmt.Println(completion)
+	switch args[0] {
+	case "-complete-v1":
+		writeCandidates(w, candidates)
+	case "-complete-desc":
+		for _, candidate := range candidates {
+			if candidate.Description != "" {
+				fmt.Fprintf(w, "%s\t%s\n", candidate.Value, candidate.Description)
+			} else {
+				fmt.Fprintln(w, candidate.Value)
+			}
+		}
+	default:
+		for _, candidate := range candidates {
+			fmt.Fprintln(w, candidate.Value)
+		}
 	}
 	
 	return nil
@@ -474,24 +691,6 @@ func getStringSlice(v interface{}) []string {
 	return []string{}
 }
 
-// GenerateHelp generates help text (placeholder implementation)
-func (cmd *GSCommand) GenerateHelp() string {
-	var sb strings.Builder
-	sb.WriteString("Usage: command [options]\n\nOptions:\n")
-	
-	for _, field := range cmd.fields {
-		flag := parseFlagName(field.Name)
-		sb.WriteString(fmt.Sprintf("  %-15s %s\n", flag, field.Help))
-	}
-	
-	return sb.String()
-}
-
-// GenerateManPage generates a man page (placeholder implementation)
-func (cmd *GSCommand) GenerateManPage() string {
-	return "Man page not implemented yet"
-}
-
 // SetCompleter sets the completion handler
 func (cmd *GSCommand) SetCompleter(completer Completer) {
 	cmd.completer = completer
@@ -502,33 +701,125 @@ func (cmd *GSCommand) GetFields() []FieldMeta {
 	return cmd.fields
 }
 
+// scriptName returns the name of the binary completion scripts are for, which
+// is the group's for a subcommand
+func (cmd *GSCommand) scriptName() string {
+	if cmd.group != nil {
+		return cmd.group.name
+	}
+	return cmd.commandName
+}
+
 // generateBashCompletion generates a bash completion script
 func (cmd *GSCommand) generateBashCompletion() string {
-	// Use the stored command name
-	commandName := cmd.commandName
-	
-	return fmt.Sprintf(`# Bash completion for %s
-_%s_completion() {
-    local cur prev words cword
-    
-    # Basic completion setup without _init_completion dependency
-    cur="${COMP_WORDS[COMP_CWORD]}"
-    prev="${COMP_WORDS[COMP_CWORD-1]}"
+	return bashCompletionScript(cmd.scriptName())
+}
+
+// generateZshCompletion generates a zsh completion script
+func (cmd *GSCommand) generateZshCompletion() string {
+	return zshCompletionScript(cmd.scriptName())
+}
+
+// generateFishCompletion generates a fish completion script
+func (cmd *GSCommand) generateFishCompletion() string {
+	return fishCompletionScript(cmd.scriptName())
+}
+
+// bashCompletionScript generates a bash completion script for commandName
+func bashCompletionScript(commandName string) string {
+	return fmt.Sprintf(`# Bash completion for %[1]s
+_%[1]s_completion() {
+    local value hints nospace=0
+    COMPREPLY=()
     
-    # Call the command with -complete to get all suggestions
-    # The Go binary now handles all completion logic internally
-    local completions
-    completions=$(%s -complete $((COMP_CWORD-1)) "${COMP_WORDS[@]:1}" 2>/dev/null)
+    # The command splits COMP_LINE itself, so that quoted words and words
+    # containing COMP_WORDBREAKS characters such as ':' survive, and prints
+    # "value<TAB>hints<TAB>description" lines whose values are already
+    # escaped for insertion; bash only uses the values
+    while IFS=$'\t' read -r value hints _; do
+        [[ -z "$value" ]] && continue
+        COMPREPLY+=("$value")
+        [[ ",$hints," == *,nospace,* ]] && nospace=1
+    done < <(COMP_WORDBREAKS="$COMP_WORDBREAKS" %[1]s -complete-bash "$COMP_POINT" "$COMP_LINE" 2>/dev/null)
     
-    # Generate completion replies from command output
-    if [[ -n "$completions" ]]; then
-        COMPREPLY=($(compgen -W "$completions" -- "$cur"))
-    fi
+    # Don't add a space after directories so that their contents can follow
+    (( nospace )) && compopt -o nospace 2>/dev/null
+    return 0
 }
 
 # Register the completion function
-complete -F _%s_completion %s
-`, commandName, commandName, commandName, commandName, commandName)
+complete -F _%[1]s_completion %[1]s
+`, commandName)
+}
+
+// zshCompletionScript generates a zsh completion script for commandName.
+// Candidates are read from -complete-v1 so that descriptions are shown
+// next to each one.
+func zshCompletionScript(commandName string) string {
+	return fmt.Sprintf(`#compdef %[1]s
+# Zsh completion for %[1]s
+_%[1]s() {
+    local -a values displays nospace_values nospace_displays
+    local value hints desc display
+    
+    # words[1] is the command itself; positions passed to -complete-v1 are
+    # relative to the first argument. Words are passed unquoted, and compadd
+    # quotes the candidates to suit the word being completed
+    while IFS=$'\t' read -r value hints desc; do
+        [[ -z "$value" ]] && continue
+        display="$value"
+        [[ -n "$desc" ]] && display="$value -- $desc"
+        if [[ ",$hints," == *,nospace,* ]]; then
+            nospace_values+=("$value")
+            nospace_displays+=("$display")
+        else
+            values+=("$value")
+            displays+=("$display")
+        fi
+    done < <(%[1]s -complete-v1 $((CURRENT-2)) "${(@Q)words[2,CURRENT-1]}" "${(Q)PREFIX}" "${(@Q)words[CURRENT+1,-1]}" 2>/dev/null)
+    
+    (( ${#values} )) && compadd -l -d displays -a values
+    (( ${#nospace_values} )) && compadd -S '' -l -d nospace_displays -a nospace_values
+}
+
+# Support both autoloading from $fpath and eval "$(%[1]s -zsh-completion)"
+if [[ "${funcstack[1]}" == "_%[1]s" ]]; then
+    _%[1]s "$@"
+else
+    compdef _%[1]s %[1]s
+fi
+`, commandName)
+}
+
+// fishCompletionScript generates a fish completion script for commandName.
+// The "value<TAB>hints<TAB>description" lines of -complete-v1 are turned
+// into the "value<TAB>description" lines fish understands.
+func fishCompletionScript(commandName string) string {
+	return fmt.Sprintf(`# Fish completion for %[1]s
+function __%[1]s_complete
+    set -l tokens (commandline -opc)
+    # Tokens before the cursor are already unquoted; unquote the current one
+    set -l current (commandline -ct)
+    if string match -qr '^\'' -- $current
+        set current (string sub -s 2 -- $current)
+    else if string match -qr '^"' -- $current
+        set current (string sub -s 2 -- $current | string replace -ra '\\\\([\\\\"$])' '$1')
+    else
+        set current (string unescape -- $current; or echo $current)
+    end
+    set -l pos (math (count $tokens) - 1)
+    %[1]s -complete-v1 $pos $tokens[2..-1] "$current" 2>/dev/null | while read -l -d \t value hints desc
+        if test -n "$desc"
+            printf '%%s\t%%s\n' $value $desc
+        else
+            printf '%%s\n' $value
+        end
+    end
+end
+
+# File candidates come from the command itself, so disable fish's own
+complete -c %[1]s -f -a '(__%[1]s_complete)'
+`, commandName)
 }
 
 // getFlagNames returns a space-separated list of all flag names
@@ -540,10 +831,33 @@ func (cmd *GSCommand) getFlagNames() string {
 		flags = append(flags, "+"+flag[1:])  // Add +flag (remove - and add +)
 	}
 	// Add common flags (these don't typically have + versions)
-	flags = append(flags, "-help", "-man", "-complete", "-bash-completion")
+	for _, special := range specialFlags {
+		flags = append(flags, special.Name)
+	}
 	return strings.Join(flags, " ")
 }
 
+// specialFlags are handled by Execute before argument parsing
+var specialFlags = []struct {
+	Name string
+	Help string
+}{
+	{"-help", "Show help"},
+	{"-man", "Show manual page"},
+	{"-complete", "Print completions for a command line"},
+	{"-bash-completion", "Print bash completion script"},
+	{"-zsh-completion", "Print zsh completion script"},
+	{"-fish-completion", "Print fish completion script"},
+}
+
+// Candidate is a completion candidate with an optional description
+type Candidate struct {
+	Value       string
+	Description string
+	Kind        string // What the candidate is: flag, field, value, enum, file or dir
+	NoSpace     bool   // The shell should not add a space, e.g. after a directory
+}
+
 // CompletionContext represents the context for command completion
 type CompletionContext struct {
 	Type          CompletionType // What kind of completion this is
@@ -565,6 +879,8 @@ const (
 	CompletionFile
 	CompletionMultiArg
 	CompletionEnum
+	CompletionResponseFile
+	CompletionPreset
 )
 
 // complete provides completion for command line arguments
@@ -575,20 +891,196 @@ func (cmd *GSCommand) complete(args []string, pos int) ([]string, error) {
 	case CompletionFlag:
 		return cmd.completeFlags(context.Current), nil
 	case CompletionField:
-		return cmd.completeField(context.TSVFile, context.Current)
+		fields, err := cmd.completeField(context.TSVFile, context.Current)
+		return cmd.fieldsOfKind(context.TSVFile, context.FieldMeta, fields), err
 	case CompletionContent:
-		return cmd.completeContent(context.TSVFile, context.FieldName, context.Current)
+		return cmd.completeContent(context.TSVFile, context.FieldName, context.Current, cmd.sampleDepth(context.FieldMeta))
 	case CompletionMultiArg:
-		return cmd.completeMultiArgument(context)
+		completions, err := cmd.completeMultiArgument(context)
+		if context.ArgumentSpec != nil && context.ArgumentSpec.Type == ArgumentTypeField {
+			completions = cmd.fieldsOfKind(context.TSVFile, context.FieldMeta, completions)
+		}
+		return completions, err
 	case CompletionEnum:
 		return cmd.completeEnum(context.FieldMeta, context.Current), nil
 	case CompletionFile:
 		return cmd.completeFilesWithSuffix(context.Current, context.FieldMeta)
+	case CompletionResponseFile:
+		files, err := cmd.completeFiles(context.Current[1:])
+		for i := range files {
+			files[i] = "@" + files[i]
+		}
+		return files, err
+	case CompletionPreset:
+		return cmd.completePresets(context.Current), nil
 	default:
 		return cmd.completeFilesWithSuffix(context.Current, nil)
 	}
 }
 
+// completeDescribed provides completion candidates annotated with their kind
+// and a description: the help text of flags and enum switches, the type and
+// example values of fields, and whether a candidate takes no trailing space.
+func (cmd *GSCommand) completeDescribed(args []string, pos int) ([]Candidate, error) {
+	completions, err := cmd.complete(args, pos)
+	if err != nil {
+		return nil, err
+	}
+	
+	context := cmd.analyzeCompletionContext(args, pos)
+	kind := completionKind(context)
+	var examples map[string]string
+	var kinds map[string]tsv.Kind
+	if kind == "field" && context.TSVFile != "" {
+		examples = cmd.fieldExamples(context.TSVFile)
+		kinds, _ = cmd.ColumnKinds(context.TSVFile)
+	}
+	var presets map[string]preset
+	if context.Type == CompletionPreset {
+		presets = cmd.presets()
+	}
+	
+	candidates := make([]Candidate, len(completions))
+	for i, completion := range completions {
+		candidate := Candidate{Value: completion, Kind: kind}
+		if p, ok := presets[strings.TrimPrefix(completion, "@@")]; ok {
+			candidate.Description = p.String()
+		}
+		switch kind {
+		case "flag":
+			candidate.Description = cmd.flagDescription(completion)
+		case "enum":
+			candidate.Description = context.FieldMeta.Help
+		case "field":
+			candidate.Description = examples[completion]
+			if kind, ok := kinds[completion]; ok {
+				if candidate.Description != "" {
+					candidate.Description = kind.String() + ", " + candidate.Description
+				} else {
+					candidate.Description = kind.String()
+				}
+			}
+		case "file":
+			if strings.HasSuffix(completion, "/") {
+				candidate.Kind = "dir"
+				candidate.NoSpace = true
+			}
+		}
+		candidates[i] = candidate
+	}
+	
+	return candidates, nil
+}
+
+// completionKind names what complete offers in a context
+func completionKind(context CompletionContext) string {
+	switch context.Type {
+	case CompletionFlag:
+		return "flag"
+	case CompletionField:
+		return "field"
+	case CompletionContent, CompletionPreset:
+		return "value"
+	case CompletionEnum:
+		return "enum"
+	case CompletionMultiArg:
+		switch context.ArgumentSpec.Type {
+		case ArgumentTypeField:
+			return "field"
+		case ArgumentTypeContent, ArgumentTypeNumber:
+			return "value"
+		}
+	}
+	return "file"
+}
+
+// fieldExamples describes each field of a file by the first few distinct
+// values in its opening rows, e.g. "e.g. 25, 35, 45"
+func (cmd *GSCommand) fieldExamples(filename string) map[string]string {
+	const rows, perField = 20, 3
+	
+	file, err := tsv.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	
+	reader := tsv.NewReader(file)
+	header, err := reader.Header()
+	if err != nil {
+		return nil
+	}
+	
+	values := make([][]string, len(header))
+	for row := 0; row < rows; row++ {
+		record, err := reader.Read()
+		if err != nil {
+			break
+		}
+		for i := range header {
+			if i < len(record) && record[i] != "" && len(values[i]) < perField && !slices.Contains(values[i], record[i]) {
+				values[i] = append(values[i], record[i])
+			}
+		}
+	}
+	
+	examples := make(map[string]string, len(header))
+	for i, name := range header {
+		if len(values[i]) > 0 {
+			examples[name] = "e.g. " + strings.Join(values[i], ", ")
+		}
+	}
+	return examples
+}
+
+// writeCandidates prints candidates for -complete-v1, one per line as
+// "value<TAB>hints<TAB>description". hints is a comma-separated list of the
+// candidate's kind and, for candidates after which the shell should not add a
+// space, "nospace". Tabs and newlines in descriptions are replaced by spaces,
+// and values containing them are skipped as they cannot be represented.
+func writeCandidates(w io.Writer, candidates []Candidate) {
+	clean := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	for _, candidate := range candidates {
+		if strings.ContainsAny(candidate.Value, "\t\n\r") {
+			continue
+		}
+		hints := candidate.Kind
+		if candidate.NoSpace {
+			hints += ",nospace"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", candidate.Value, hints, clean.Replace(candidate.Description))
+	}
+}
+
+// flagDescription returns the help text for a -flag or +flag
+func (cmd *GSCommand) flagDescription(flag string) string {
+	for _, special := range specialFlags {
+		if flag == special.Name {
+			return special.Help
+		}
+	}
+	if flag == presetFlag && cmd.usesPresetFlag() {
+		return "Expand a preset from a config file"
+	}
+	
+	negated := strings.HasPrefix(flag, "+")
+	normalized := "-" + strings.TrimLeft(flag, "-+")
+	for _, field := range cmd.fields {
+		if parseFlagName(field.Name) != normalized {
+			continue
+		}
+		help := field.Help
+		if field.Required {
+			help = "(required) " + help
+		}
+		if negated {
+			return "negate: " + help
+		}
+		return help
+	}
+	return ""
+}
+
 // isFieldFlag checks if a flag expects a field name
 func (cmd *GSCommand) isFieldFlag(flagName string) bool {
 	for _, field := range cmd.fields {
@@ -602,15 +1094,15 @@ func (cmd *GSCommand) isFieldFlag(flagName string) bool {
 // findTSVFile searches for TSV files in command arguments
 func (cmd *GSCommand) findTSVFile(args []string) string {
 	for i, arg := range args {
-		// Case 1: TSV/CSV file after flags like -argv
+		// Case 1: TSV/CSV file, possibly compressed, after flags like -argv
 		if i > 0 && (args[i-1] == "-argv" || strings.HasSuffix(args[i-1], "-file")) {
-			if strings.HasSuffix(arg, ".tsv") || strings.HasSuffix(arg, ".csv") {
+			if tsv.IsDataFile(arg) {
 				return arg
 			}
 		}
 		
 		// Case 2: Direct TSV/CSV file argument (bare argument, not following a flag)
-		if (strings.HasSuffix(arg, ".tsv") || strings.HasSuffix(arg, ".csv")) && !strings.HasPrefix(arg, "-") {
+		if tsv.IsDataFile(arg) && !strings.HasPrefix(arg, "-") {
 			// Make sure it's not immediately after a flag that takes a value (exclude -argv case handled above)
 			if i == 0 || !strings.HasPrefix(args[i-1], "-") || args[i-1] == "-" || args[i-1] == "+" {
 				return arg // This is a positional argument
@@ -623,9 +1115,14 @@ func (cmd *GSCommand) findTSVFile(args []string) string {
 
 // completeField provides field name completion for a TSV file
 func (cmd *GSCommand) completeField(filename, partial string) ([]string, error) {
-	fields, err := cmd.getFields(filename)
-	if err != nil {
-		return nil, err
+	var fields []string
+	if filename == "" {
+		_, fields = cmd.pipedInput()
+	} else {
+		var err error
+		if fields, err = cmd.getFields(filename, true); err != nil {
+			return nil, err
+		}
 	}
 	
 	var matches []string
@@ -640,58 +1137,36 @@ func (cmd *GSCommand) completeField(filename, partial string) ([]string, error)
 	return matches, nil
 }
 
-// getFields reads and caches field names from a TSV file
-func (cmd *GSCommand) getFields(filename string) ([]string, error) {
+// getFields reads and caches field names from a TSV file. appended allows the
+// fields cached before a large file was appended to, for completion.
+func (cmd *GSCommand) getFields(filename string, appended bool) ([]string, error) {
 	// Check cache first
-	if fields, exists := cmd.fieldCache[filename]; exists {
+	fields, exists := lookupCache(cmd, cmd.fieldCache, filename, filename, appended, func(entry *cacheEntry) ([]string, bool) {
+		return entry.Fields, entry.Fields != nil
+	})
+	if exists {
 		return fields, nil
 	}
 	
-	file, err := os.Open(filename)
+	file, err := tsv.Open(filename)
 	if err != nil {
-		return nil, fmt.Errorf("opening file %s: %w", filename, err)
+		return nil, err
 	}
 	defer file.Close()
 	
-	scanner := bufio.NewScanner(file)
-	if !scanner.Scan() {
-		return nil, fmt.Errorf("file %s is empty", filename)
+	header, err := tsv.ReadHeader(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
 	}
-	
-	headerLine := scanner.Text()
-	fields := cmd.parseTSVHeader(headerLine)
+	fields = []string(header)
 	
 	// Cache the result
 	cmd.fieldCache[filename] = fields
-	
-	return fields, nil
-}
-
-// parseTSVHeader parses a TSV header line into field names
-func (cmd *GSCommand) parseTSVHeader(header string) []string {
-	// Remove leading non-alphanumeric characters (common in TSV files)
-	header = strings.TrimLeftFunc(header, func(r rune) bool {
-		return !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'))
+	cmd.updateCache(filename, func(entry *cacheEntry) {
+		entry.Fields = fields
 	})
 	
-	// Split on tabs first, then commas as fallback
-	var fields []string
-	if strings.Contains(header, "\t") {
-		fields = strings.Split(header, "\t")
-	} else {
-		fields = strings.Split(header, ",")
-	}
-	
-	// Clean up field names
-	var cleanFields []string
-	for _, field := range fields {
-		field = strings.TrimSpace(field)
-		if field != "" {
-			cleanFields = append(cleanFields, field)
-		}
-	}
-	
-	return cleanFields
+	return fields, nil
 }
 
 // analyzeCompletionContext analyzes the command line to determine completion context
@@ -711,11 +1186,30 @@ func (cmd *GSCommand) analyzeCompletionContext(args []string, pos int) Completio
 		return context
 	}
 	
-	// Find TSV file for field/content completion
-	context.TSVFile = cmd.findTSVFile(args)
+	// Presets are named after -preset or as @@name
+	if isPresetRef(context.Current) || context.Current == "@@" ||
+		(pos > 0 && pos <= len(args) && args[pos-1] == presetFlag && cmd.usesPresetFlag()) {
+		context.Type = CompletionPreset
+		return context
+	}
+	
+	// Find TSV file for field/content completion, which may be named in a
+	// response file
+	context.TSVFile = cmd.findTSVFile(expandResponseFiles(args))
+	if context.TSVFile == "" {
+		// Piped input: complete from GS_HEADER or the last input's sidecar
+		context.TSVFile, _ = cmd.pipedInput()
+	}
 	
 	// Analyze backwards to find the flag that might need completion
 	flagPos, fieldMeta := cmd.findLastFlag(args, pos)
+	
+	// Response files can be named wherever a flag could be
+	if strings.HasPrefix(context.Current, "@") &&
+		(fieldMeta == nil || pos-flagPos-1 >= len(argumentPlaceholders(*fieldMeta))) {
+		context.Type = CompletionResponseFile
+		return context
+	}
 	if fieldMeta == nil {
 		// No flag found, this might be a bare file argument
 		// Check if we should apply -argv completion behavior
@@ -767,10 +1261,14 @@ func (cmd *GSCommand) analyzeCompletionContext(args []string, pos int) Completio
 			context.ArgumentIndex = argIndex
 			context.ArgumentSpec = &fieldMeta.Args[argIndex]
 			
-			// Set field name if we're completing content after a field argument
-			if argIndex > 0 && fieldMeta.Args[argIndex-1].Type == ArgumentTypeField {
-				if flagPos+argIndex < len(args) {
-					context.FieldName = args[flagPos+argIndex]
+			// Set field name from the nearest field argument before this one,
+			// so content and number arguments can complete from its values
+			for j := argIndex - 1; j >= 0; j-- {
+				if fieldMeta.Args[j].Type == ArgumentTypeField {
+					if flagPos+j+1 < len(args) {
+						context.FieldName = args[flagPos+j+1]
+					}
+					break
 				}
 			}
 		} else {
@@ -840,14 +1338,28 @@ func (cmd *GSCommand) completeMultiArgument(context CompletionContext) ([]string
 	
 	switch context.ArgumentSpec.Type {
 	case ArgumentTypeField:
-		if context.TSVFile != "" {
-			return cmd.completeField(context.TSVFile, context.Current)
+		return cmd.completeField(context.TSVFile, context.Current)
+		
+	case ArgumentTypeContent:
+		if context.TSVFile != "" && context.FieldName != "" {
+			return cmd.completeContent(context.TSVFile, context.FieldName, context.Current, cmd.sampleDepth(context.FieldMeta))
 		}
 		return []string{}, nil
 		
-	case ArgumentTypeContent:
+	case ArgumentTypeNumber:
+		// Offer the numeric values seen in the field, e.g. for -gt cpu_usage
 		if context.TSVFile != "" && context.FieldName != "" {
-			return cmd.completeContent(context.TSVFile, context.FieldName, context.Current)
+			values, err := cmd.completeContent(context.TSVFile, context.FieldName, context.Current, cmd.sampleDepth(context.FieldMeta))
+			if err != nil {
+				return values, err
+			}
+			numbers := []string{}
+			for _, value := range values {
+				if _, err := parseNumber(value); err == nil {
+					numbers = append(numbers, value)
+				}
+			}
+			return numbers, nil
 		}
 		return []string{}, nil
 		
@@ -860,9 +1372,10 @@ func (cmd *GSCommand) completeMultiArgument(context CompletionContext) ([]string
 	}
 }
 
-// completeContent provides completion for field content
-func (cmd *GSCommand) completeContent(filename, fieldName, partial string) ([]string, error) {
-	values, err := cmd.getFieldValues(filename, fieldName)
+// completeContent provides completion for field content, sampling depth
+// values from the field, most frequent first
+func (cmd *GSCommand) completeContent(filename, fieldName, partial string, depth int) ([]string, error) {
+	values, err := cmd.getFieldValues(filename, fieldName, depth)
 	if err != nil {
 		return []string{}, nil // Return empty on error rather than failing
 	}
@@ -879,89 +1392,54 @@ func (cmd *GSCommand) completeContent(filename, fieldName, partial string) ([]st
 	return matches, nil
 }
 
-// getFieldValues scans TSV file and returns unique values for a specific field
-func (cmd *GSCommand) getFieldValues(filename, fieldName string) ([]string, error) {
-	// Check cache first
-	if fileCache, exists := cmd.contentCache[filename]; exists {
-		if values, exists := fileCache[fieldName]; exists {
-			return values, nil
-		}
-	}
-	
+// getFieldValues samples a TSV file and returns the distinct values of a
+// specific field, most frequent first
+func (cmd *GSCommand) getFieldValues(filename, fieldName string, depth int) ([]string, error) {
 	// Initialize file cache if needed
 	if _, exists := cmd.contentCache[filename]; !exists {
 		cmd.contentCache[filename] = make(map[string][]string)
 	}
 	
-	// Open file and parse content
-	file, err := os.Open(filename)
+	// Check cache first
+	values, exists := lookupCache(cmd, cmd.contentCache[filename], fieldName, filename, true, func(entry *cacheEntry) ([]string, bool) {
+		values, exists := entry.Values[fieldName]
+		return values, exists && entry.Depth == depth
+	})
+	if exists {
+		return values, nil
+	}
+	
+	// Open file, decompressing if needed, and parse content
+	file, err := tsv.Open(filename)
 	if err != nil {
-		return nil, fmt.Errorf("opening file %s: %w", filename, err)
+		return nil, err
 	}
 	defer file.Close()
 	
-	scanner := bufio.NewScanner(file)
-	if !scanner.Scan() {
-		return nil, fmt.Errorf("file %s is empty", filename)
+	reader := tsv.NewReader(file)
+	header, err := reader.Header()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
 	}
 	
-	// Parse header to get field positions
-	headerLine := scanner.Text()
-	fields := cmd.parseTSVHeader(headerLine)
-	
 	// Find the field index
-	fieldIndex := -1
-	for i, field := range fields {
-		if field == fieldName {
-			fieldIndex = i
-			break
-		}
-	}
-	
+	fieldIndex := header.Index(fieldName)
 	if fieldIndex == -1 {
 		return []string{}, nil // Field not found
 	}
 	
-	// Scan content lines
-	values := make(map[string]bool) // Use map to track unique values
-	linesScanned := 0
-	
-	for scanner.Scan() && linesScanned < cmd.scanDepth {
-		line := scanner.Text()
-		parts := strings.Split(line, "\t")
-		
-		// Use comma fallback if not enough tab-separated parts
-		if len(parts) <= fieldIndex {
-			parts = strings.Split(line, ",")
-		}
-		
-		if fieldIndex < len(parts) {
-			value := strings.TrimSpace(parts[fieldIndex])
-			if value != "" {
-				values[value] = true
-			}
-		}
-		
-		linesScanned++
-	}
-	
-	// Convert map to sorted slice
-	result := make([]string, 0, len(values))
-	for value := range values {
-		result = append(result, value)
-	}
-	
-	// Simple sort for consistent ordering
-	for i := 0; i < len(result)-1; i++ {
-		for j := i + 1; j < len(result); j++ {
-			if result[i] > result[j] {
-				result[i], result[j] = result[j], result[i]
-			}
-		}
-	}
+	// Sample the whole file, within the time budget
+	result := sampleValues(reader, fieldIndex, depth, cmd.sampleBudget())
 	
 	// Cache the result
 	cmd.contentCache[filename][fieldName] = result
+	cmd.updateCache(filename, func(entry *cacheEntry) {
+		if entry.Values == nil || entry.Depth != depth {
+			entry.Values = make(map[string][]string)
+			entry.Depth = depth
+		}
+		entry.Values[fieldName] = result
+	})
 	
 	return result, nil
 }
@@ -971,8 +1449,8 @@ func (cmd *GSCommand) completeFlags(partial string) []string {
 	var matches []string
 	partial = strings.ToLower(partial)
 	
-	// Add command-specific flags (both - and + versions)
-	for _, field := range cmd.fields {
+	// Add command-specific flags (both - and + versions), required ones first
+	for _, field := range cmd.orderedFields() {
 		flag := parseFlagName(field.Name)
 		
 		// Add -flag version
@@ -987,11 +1465,15 @@ func (cmd *GSCommand) completeFlags(partial string) []string {
 		}
 	}
 	
+	// -preset is offered once there are presets to name
+	if strings.HasPrefix(presetFlag, partial) && cmd.usesPresetFlag() && len(cmd.presets()) > 0 {
+		matches = append(matches, presetFlag)
+	}
+	
 	// Add common flags (these don't typically have + versions)
-	commonFlags := []string{"-help", "-man", "-complete", "-bash-completion"}
-	for _, flag := range commonFlags {
-		if strings.HasPrefix(strings.ToLower(flag), partial) {
-			matches = append(matches, flag)
+	for _, special := range specialFlags {
+		if strings.HasPrefix(strings.ToLower(special.Name), partial) {
+			matches = append(matches, special.Name)
 		}
 	}
 	
@@ -1068,7 +1550,7 @@ func (cmd *GSCommand) completeFilesWithSuffix(partial string, fieldMeta *FieldMe
 		}
 		
 		// Prioritize TSV files (if no specific suffix required, or if suffix is .tsv)
-		if strings.HasSuffix(strings.ToLower(name), ".tsv") {
+		if strings.HasSuffix(strings.ToLower(tsv.TrimCompression(name)), ".tsv") {
 			tsvFiles = append(tsvFiles, fullPath)
 		} else {
 			otherFiles = append(otherFiles, fullPath)
@@ -1100,8 +1582,12 @@ func (cmd *GSCommand) completeEnum(fieldMeta *FieldMeta, partial string) []strin
 }
 
 // matchesSuffixPattern checks if a filename matches a suffix pattern
-// Supports simple suffixes (.tsv), character classes (.[tc]sv), and brace expansion (.{tsv,csv})
+// Supports simple suffixes (.tsv), character classes (.[tc]sv), and brace expansion (.{tsv,csv}).
+// Compressed files match by their uncompressed name, so data.tsv.gz matches .tsv.
 func matchesSuffixPattern(filename, pattern string) bool {
+	if trimmed := tsv.TrimCompression(filename); trimmed != filename && matchesSuffixPattern(trimmed, pattern) {
+		return true
+	}
 	filename = strings.ToLower(filename)
 	pattern = strings.ToLower(pattern)
 	
@@ -2,11 +2,36 @@ package gs
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/rosscartlidge/gogstools/gs/tsv"
 )
 
+// TestMain keeps the caches written by tests out of the user's cache directory,
+// and the user's config files out of the tests
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gs-test-cache")
+	if err != nil {
+		panic(err)
+	}
+	for _, env := range []string{"XDG_CACHE_HOME", "XDG_CONFIG_HOME", "HOME", "LocalAppData", "AppData"} {
+		os.Setenv(env, dir)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 // TestConfig is a simple test configuration
 type TestConfig struct {
 	Name    string   `gs:"string,global,last,help=Name of the test,default=test"`
@@ -291,7 +316,7 @@ func TestMultiArgumentSwitchCompletion(t *testing.T) {
 	}
 
 	// Test content completion
-	contentCompletions, err := cmd.completeContent("../examples/chart/testdata/sample.tsv", "cpu_usage", "")
+	contentCompletions, err := cmd.completeContent("../examples/chart/testdata/sample.tsv", "cpu_usage", "", 100)
 	if err != nil {
 		t.Fatalf("Content completion failed: %v", err)
 	}
@@ -409,4 +434,1878 @@ func TestValidationIntegration(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestDescribedCompletion(t *testing.T) {
+	config := &TestCompletionConfig{}
+	cmd, err := NewCommand(config)
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	candidates, err := cmd.completeDescribed([]string{"-ty"}, 0)
+	if err != nil {
+		t.Fatalf("Completion failed: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Value != "-type" || candidates[0].Description != "Type field" {
+		t.Errorf("Expected -type described as 'Type field', got %v", candidates)
+	}
+
+	candidates, err = cmd.completeDescribed([]string{"+ty"}, 0)
+	if err != nil {
+		t.Fatalf("Completion failed: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Description != "negate: Type field" {
+		t.Errorf("Expected negated description, got %v", candidates)
+	}
+}
+
+func TestCompletionProtocol(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.tsv")
+	if err := os.WriteFile(data, []byte("time\tcpu\n1\t25\n2\t35\n3\t25\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "logs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &TestCompletionConfig{}
+	cmd, err := NewCommand(config)
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	candidates, err := cmd.completeDescribed([]string{data, "-field", ""}, 2)
+	if err != nil {
+		t.Fatalf("Completion failed: %v", err)
+	}
+	if len(candidates) != 2 || candidates[1].Kind != "field" || candidates[1].Description != "int, e.g. 25, 35" {
+		t.Errorf("Expected fields described by kind and example values, got %v", candidates)
+	}
+
+	candidates, err = cmd.completeDescribed([]string{filepath.Join(dir, "lo")}, 0)
+	if err != nil {
+		t.Fatalf("Completion failed: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Kind != "dir" || !candidates[0].NoSpace {
+		t.Errorf("Expected a directory without trailing space, got %v", candidates)
+	}
+
+	var out strings.Builder
+	writeCandidates(&out, []Candidate{
+		{Value: "-type", Kind: "flag", Description: "Type\tfield"},
+		{Value: "logs/", Kind: "dir", NoSpace: true},
+	})
+	want := "-type\tflag\tType field\nlogs/\tdir,nospace\t\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		words []string
+		quote byte
+		start int
+	}{
+		{`tool -match city `, []string{"tool", "-match", "city", ""}, 0, 0},
+		{`tool "New Y`, []string{"tool", "New Y"}, '"', 0},
+		{`tool New\ Y`, []string{"tool", "New Y"}, 0, 0},
+		{`tool 'it'\''s $HO`, []string{"tool", "it's $HO"}, '\'', 3},
+		{`tool "a \"b\" \$c`, []string{"tool", `a "b" $c`}, '"', 0},
+		{`tool 12:3`, []string{"tool", "12:3"}, 0, 3},
+		{`tool "12:3`, []string{"tool", "12:3"}, '"', 0},
+		{`tool 1:2:"3:4`, []string{"tool", "1:2:3:4"}, '"', 4},
+		{`tool "a:b"c`, []string{"tool", "a:bc"}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		words, quote, start := splitCommandLine(tt.line, defaultWordBreaks)
+		if !slices.Equal(words, tt.words) || quote != tt.quote || start != tt.start {
+			t.Errorf("splitCommandLine(%q) = %q, %q, %d; want %q, %q, %d",
+				tt.line, words, quote, start, tt.words, tt.quote, tt.start)
+		}
+	}
+}
+
+func TestQuoteForBash(t *testing.T) {
+	values := []string{"New York", "it's $HOME", "a*b?[c]", "`id`;rm -rf ~", "naïve café", `back\slash "quoted"`}
+	for _, value := range values {
+		for _, quote := range []byte{0, '\'', '"'} {
+			escaped, ok := quoteForBash(value, quote)
+			if !ok {
+				t.Errorf("quoteForBash(%q, %q) failed", value, quote)
+				continue
+			}
+			// Reading the completed word back must give the value
+			open := ""
+			if quote != 0 {
+				open = string(quote)
+			}
+			words, _, _ := splitCommandLine("tool "+open+escaped+open, defaultWordBreaks)
+			if len(words) != 2 || words[1] != value {
+				t.Errorf("quoteForBash(%q, %q) = %q, which reads back as %q", value, quote, escaped, words[1:])
+			}
+		}
+	}
+
+	// Control characters need ANSI-C quoting, which can't be used inside quotes
+	if escaped, ok := quoteForBash("a\tb", 0); !ok || escaped != `a$'\x09'b` {
+		t.Errorf("Expected ANSI-C quoted tab, got %q", escaped)
+	}
+	if _, ok := quoteForBash("a\tb", '"'); ok {
+		t.Errorf("Expected a tab inside quotes to be rejected")
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.tsv")
+	content := "time\tcity\n12:30\tNew York\n13:00\tit's $HOME\n"
+	if err := os.WriteFile(data, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &TestCompletionConfig{}
+	cmd, err := NewCommand(config)
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	// | marks the cursor
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"-match city |", []string{`New\ York`, `it\'s\ \$HOME`}},
+		{`-match city "N|`, []string{"New York"}},
+		{`-match city 'it|`, []string{`it'\''s $HOME`}},
+		{"-match time 12:|", []string{"30"}},
+		{"-match city N| -name x", []string{`New\ York`}},
+	}
+
+	for _, tt := range tests {
+		line := "tool " + data + " " + tt.line
+		point := strings.Index(line, "|")
+		line = line[:point] + line[point+1:]
+
+		var out strings.Builder
+		if err := writeBashCandidates(&out, line, point, cmd.completeDescribed); err != nil {
+			t.Fatalf("Completion failed: %v", err)
+		}
+		var values []string
+		for _, candidate := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+			if candidate != "" {
+				values = append(values, strings.Split(candidate, "\t")[0])
+			}
+		}
+		if !slices.Equal(values, tt.want) {
+			t.Errorf("Completing %q: expected %q, got %q", tt.line, tt.want, values)
+		}
+	}
+}
+
+func TestShellCompletionScripts(t *testing.T) {
+	config := &TestCompletionConfig{}
+	cmd, err := NewCommand(config)
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	cmd.commandName = "mytool"
+
+	zsh := cmd.generateZshCompletion()
+	for _, want := range []string{"#compdef mytool", "mytool -complete-v1", "compdef _mytool mytool", "compadd -S ''"} {
+		if !strings.Contains(zsh, want) {
+			t.Errorf("zsh script missing %q:\n%s", want, zsh)
+		}
+	}
+
+	bash := cmd.generateBashCompletion()
+	for _, want := range []string{"mytool -complete-bash \"$COMP_POINT\" \"$COMP_LINE\"", "compopt -o nospace", "complete -F _mytool_completion mytool"} {
+		if !strings.Contains(bash, want) {
+			t.Errorf("bash script missing %q:\n%s", want, bash)
+		}
+	}
+
+	fish := cmd.generateFishCompletion()
+	for _, want := range []string{"mytool -complete-v1", "complete -c mytool"} {
+		if !strings.Contains(fish, want) {
+			t.Errorf("fish script missing %q:\n%s", want, fish)
+		}
+	}
+}
+
+// documentedConfig supplies its own documentation
+type documentedConfig struct {
+	Type  string                   `gs:"string,global,last,help=Type field,enum=bar:line:area,default=bar"`
+	File  string                   `gs:"file,global,last,help=File path,suffix=.tsv"`
+	Match []map[string]interface{} `gs:"multi,local,list,args=field:content,help=Match conditions"`
+}
+
+func (dc *documentedConfig) Documentation() Documentation {
+	return Documentation{
+		Name:     "mytool",
+		Summary:  "do things with TSV files",
+		Examples: []Example{{Command: "mytool -type line", Description: "Draw lines"}},
+		SeeAlso:  []string{"tsvselect(1)"},
+	}
+}
+
+func TestGenerateManPage(t *testing.T) {
+	cmd, err := NewCommand(&documentedConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	page := cmd.GenerateManPage()
+	for _, want := range []string{
+		".TH MYTOOL 1",
+		"mytool \\- do things with TSV files",
+		".SS Global options",
+		".SS Clause options",
+		"\\fB\\-match\\fR \\fIfield\\fR \\fIcontent\\fR",
+		"One of: \\fBbar\\fR, \\fBline\\fR, \\fBarea\\fR.",
+		"Default: \\fBbar\\fR.",
+		"Files matching: \\fB.tsv\\fR.",
+		".SH CLAUSES",
+		"mytool \\-type line",
+		".BR tsvselect (1)",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("man page missing %q:\n%s", want, page)
+		}
+	}
+}
+
+func TestGenerateHelp(t *testing.T) {
+	t.Setenv("COLUMNS", "100")
+	cmd, err := NewCommand(&documentedConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	help := cmd.GenerateHelp()
+	for _, want := range []string{
+		"Usage: mytool [options] [file] [clause-options]",
+		"-type <bar|line|area>",
+		"one of: bar, line, area; default: bar",
+		"-file <file>",
+		"files: *.tsv",
+		"-match <field> <content>",
+		"+match to negate",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help missing %q:\n%s", want, help)
+		}
+	}
+
+	for _, line := range strings.Split(help, "\n") {
+		if len(line) > 100 {
+			t.Errorf("help line exceeds terminal width: %q", line)
+		}
+	}
+}
+
+// bindConfig exercises binding of parsed values onto typed struct fields
+type bindConfig struct {
+	Count   int                      `gs:"number,global,last,help=Count"`
+	Size    uint16                   `gs:"number,global,last,help=Size"`
+	Timeout time.Duration            `gs:"string,global,last,help=Timeout,default=1m"`
+	Y       []string                 `gs:"field,local,list,help=Y fields"`
+	Match   []map[string]interface{} `gs:"multi,local,list,args=field:content,help=Match"`
+	Set     map[string]string        `gs:"multi,global,list,args=key:value,help=Settings"`
+	Range   rangeArg                 `gs:"multi,global,last,args=low:high,help=Range"`
+	Argv    string                   `gs:"file,global,last,help=Input"`
+}
+
+type rangeArg struct {
+	Low  float64
+	High float64
+}
+
+func TestBindConfig(t *testing.T) {
+	config := &bindConfig{}
+	cmd, err := NewCommand(config)
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	_, err = cmd.Parse([]string{
+		"data.tsv", "-count", "3", "-size", "512",
+		"-y", "cpu", "-y", "mem", "-match", "host", "web.*",
+		"-set", "a", "1", "-set", "b", "2", "-range", "1", "5",
+		"+", "-y", "disk",
+	})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if config.Count != 3 || config.Size != 512 {
+		t.Errorf("Expected count 3 and size 512, got %d and %d", config.Count, config.Size)
+	}
+	if config.Timeout != time.Minute {
+		t.Errorf("Expected default timeout of 1m, got %v", config.Timeout)
+	}
+	if !reflect.DeepEqual(config.Y, []string{"cpu", "mem"}) {
+		t.Errorf("Expected Y from the first clause, got %v", config.Y)
+	}
+	if len(config.Match) != 1 || config.Match[0]["field"] != "host" || config.Match[0]["content"] != "web.*" {
+		t.Errorf("Unexpected Match binding: %v", config.Match)
+	}
+	if !reflect.DeepEqual(config.Set, map[string]string{"a": "1", "b": "2"}) {
+		t.Errorf("Unexpected Set binding: %v", config.Set)
+	}
+	if config.Range != (rangeArg{Low: 1, High: 5}) {
+		t.Errorf("Unexpected Range binding: %v", config.Range)
+	}
+	if config.Argv != "data.tsv" {
+		t.Errorf("Expected bare file argument bound to Argv, got %q", config.Argv)
+	}
+
+	if _, err := cmd.Parse([]string{"-count", "1.5"}); err == nil {
+		t.Errorf("Expected error binding 1.5 to an int field")
+	}
+	if _, err := cmd.Parse([]string{"-timeout", "soon"}); err == nil {
+		t.Errorf("Expected error binding an invalid duration")
+	}
+
+	// Fields that aren't Negatable receive the bare value of a + switch
+	if _, err := cmd.Parse([]string{"+count", "4"}); err != nil || config.Count != 4 {
+		t.Errorf("Expected +count 4 to bind 4, got %d (%v)", config.Count, err)
+	}
+}
+
+func TestClauseSetDecode(t *testing.T) {
+	cmd, err := NewCommand(&bindConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	clauses, err := cmd.Parse([]string{"-y", "cpu", "+", "-y", "disk", "-y", "io"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var second struct {
+		Y     []string
+		Count int
+	}
+	if err := clauses[1].Decode(&second); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !reflect.DeepEqual(second.Y, []string{"disk", "io"}) {
+		t.Errorf("Expected second clause Y [disk io], got %v", second.Y)
+	}
+
+	if err := clauses[0].Decode(second); err == nil {
+		t.Errorf("Expected error decoding into a non-pointer")
+	}
+}
+
+func TestTypedClauses(t *testing.T) {
+	cmd, err := NewCommand(&TestCompletionConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	clauses, err := cmd.Parse([]string{
+		"-match", "host", "web", "+match", "status", "5..",
+		"+", "-match", "level", "ERROR",
+	})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	type matchArg struct {
+		Field   string
+		Content string
+		Negated bool
+	}
+	type clause struct {
+		Match     []matchArg
+		IsNegated bool
+	}
+
+	decoded, err := Clauses[clause](clauses)
+	if err != nil {
+		t.Fatalf("Clauses failed: %v", err)
+	}
+
+	expected := []clause{
+		{Match: []matchArg{{"host", "web", false}, {"status", "5..", true}}},
+		{Match: []matchArg{{"level", "ERROR", false}}, IsNegated: true},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, decoded)
+	}
+}
+
+func TestNegatableDecode(t *testing.T) {
+	cmd, err := NewCommand(&TestConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	clauses, err := cmd.Parse([]string{"-fields", "cpu", "+fields", "mem"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var c struct {
+		Fields []Negatable[string]
+		Name   Negatable[string]
+	}
+	if err := clauses[0].Decode(&c); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	expected := []Negatable[string]{{Value: "cpu"}, {Value: "mem", Negated: true}}
+	if !reflect.DeepEqual(c.Fields, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, c.Fields)
+	}
+	if c.Name != (Negatable[string]{Value: "test"}) {
+		t.Errorf("Expected default name, got %+v", c.Name)
+	}
+}
+
+// thresholdArg receives -threshold column limit by position
+type thresholdArg struct {
+	Column  string
+	Limit   int
+	Negated bool
+}
+
+type thresholdConfig struct {
+	Threshold []thresholdArg `gs:"multi,local,list,args=field:number,help=Threshold"`
+	Window    *thresholdArg  `gs:"multi,global,last,args=field:number,help=Window"`
+}
+
+func TestMultiArgumentStructBinding(t *testing.T) {
+	config := &thresholdConfig{}
+	cmd, err := NewCommand(config)
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	clauses, err := cmd.Parse([]string{"-threshold", "cpu", "90", "+threshold", "mem", "50", "-window", "time", "60"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	expected := []thresholdArg{{"cpu", 90, false}, {"mem", 50, true}}
+	if !reflect.DeepEqual(config.Threshold, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config.Threshold)
+	}
+	if config.Window == nil || *config.Window != (thresholdArg{Column: "time", Limit: 60}) {
+		t.Errorf("Unexpected Window binding: %+v", config.Window)
+	}
+
+	var c struct{ Threshold []Negatable[thresholdArg] }
+	if err := clauses[0].Decode(&c); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(c.Threshold) != 2 || !c.Threshold[1].Negated || c.Threshold[1].Value.Column != "mem" {
+		t.Errorf("Unexpected negatable decode: %+v", c.Threshold)
+	}
+
+	if _, err := cmd.Parse([]string{"-threshold", "cpu", "2.5"}); err == nil {
+		t.Errorf("Expected error binding 2.5 to an int argument")
+	}
+	if _, err := cmd.Parse([]string{"-threshold", "cpu", "high"}); err == nil {
+		t.Errorf("Expected error parsing a non-numeric number argument")
+	}
+}
+
+func TestMultiArgumentStructValidation(t *testing.T) {
+	type tooFew struct {
+		Match []struct{ Field string } `gs:"multi,local,list,args=field:content"`
+	}
+	if _, err := NewCommand(&tooFew{}); err == nil {
+		t.Errorf("Expected error for struct with too few fields")
+	}
+
+	type badNumber struct {
+		Limit []struct {
+			Field string
+			Max   bool
+		} `gs:"multi,local,list,args=field:number"`
+	}
+	if _, err := NewCommand(&badNumber{}); err == nil {
+		t.Errorf("Expected error for number argument bound to a bool")
+	}
+}
+
+type requiredConfig struct {
+	Input string   `gs:"file,global,last,required=true,help=Input file"`
+	Title string   `gs:"string,global,last,help=Title"`
+	Y     []string `gs:"field,local,list,required=true,help=Y field"`
+}
+
+func TestRequiredFields(t *testing.T) {
+	cmd, err := NewCommand(&requiredConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	if _, err := cmd.Parse([]string{"-input", "a.tsv", "-y", "cpu", "+", "-y", "mem"}); err != nil {
+		t.Errorf("Unexpected error with all required fields: %v", err)
+	}
+
+	_, err = cmd.Parse([]string{"-y", "cpu", "+", "-title", "x"})
+	if err == nil {
+		t.Fatal("Expected errors for missing required fields")
+	}
+	for _, want := range []string{
+		"validation error for field -input: required flag not given",
+		"validation error for field -y in clause 2: required flag not given",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got %q", want, err.Error())
+		}
+	}
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("Expected a ValidationError, got %T", err)
+	}
+
+	flags := cmd.completeFlags("-")
+	if len(flags) < 3 || flags[0] != "-input" || flags[1] != "-y" {
+		t.Errorf("Expected required flags first, got %v", flags)
+	}
+	if !strings.Contains(cmd.GenerateHelp(), "-input <file> *") {
+		t.Errorf("Expected required marker in help:\n%s", cmd.GenerateHelp())
+	}
+}
+
+func TestAggregateParseErrors(t *testing.T) {
+	cmd, err := NewCommand(&requiredConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	args := []string{"-inptu", "a.tsv", "-y", "cpu", "-titel", "x", "-title"}
+	_, err = cmd.Parse(args)
+	if err == nil {
+		t.Fatal("Expected parse errors")
+	}
+
+	var argErrs ArgumentErrors
+	if !errors.As(err, &argErrs) {
+		t.Fatalf("Expected ArgumentErrors, got %T", err)
+	}
+	if len(argErrs.Errors) != 4 {
+		t.Errorf("Expected 4 errors, got %d: %v", len(argErrs.Errors), argErrs.Errors)
+	}
+
+	var parseErr ParseError
+	if !errors.As(err, &parseErr) || parseErr.Position != 1 || parseErr.Value != "-inptu" {
+		t.Errorf("Expected first ParseError at position 1 for -inptu, got %+v", parseErr)
+	}
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "-input" {
+		t.Errorf("Expected ValidationError for -input, got %+v", validationErr)
+	}
+
+	expected := strings.Join([]string{
+		"4 errors",
+		"  -inptu a.tsv -y cpu -titel x -title",
+		"  ^^^^^^ unknown flag: -inptu; did you mean -input?",
+		"                      ^^^^^^ unknown flag: -titel; did you mean -title?",
+		"                               ^^^^^^ field -title: requires a value",
+		"  validation error for field -input: required flag not given",
+	}, "\n")
+	if err.Error() != expected {
+		t.Errorf("Expected rendering:\n%s\ngot:\n%s", expected, err.Error())
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	cmd, err := NewCommand(&thresholdConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	_, err = cmd.Parse([]string{"+threshold", "cpu", "high", "-window", "time", "soon"})
+	var argErrs ArgumentErrors
+	if !errors.As(err, &argErrs) || len(argErrs.Errors) != 2 {
+		t.Fatalf("Expected two errors, got %v", err)
+	}
+	for i, want := range []int{3, 6} {
+		var parseErr ParseError
+		if !errors.As(argErrs.Errors[i], &parseErr) || parseErr.Position != want {
+			t.Errorf("Error %d: expected position %d, got %+v", i, want, argErrs.Errors[i])
+		}
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	tests := []struct {
+		input      string
+		candidates []string
+		expected   string
+	}{
+		{"hieght", []string{"width", "height", "title"}, "height"},
+		{"lin", []string{"bar", "line", "area"}, "line"},
+		{"cpu_usge", []string{"time", "cpu_usage", "memory_usage"}, "cpu_usage"},
+		{"CPU_USAGE", []string{"cpu_usage"}, "cpu_usage"},
+		{"pie", []string{"bar", "line", "area"}, ""},
+		{"x", []string{"y", "z"}, ""},
+		{"xx", []string{"x", "y"}, "x"},
+		{"completely", []string{"title"}, ""},
+	}
+
+	for _, test := range tests {
+		if got := Suggest(test.input, test.candidates); got != test.expected {
+			t.Errorf("Suggest(%q) = %q, expected %q", test.input, got, test.expected)
+		}
+	}
+
+	err := UnknownFieldError("cpu_usge", "data.tsv", []string{"time", "cpu_usage"})
+	if err.Error() != "unknown field 'cpu_usge' in data.tsv; did you mean 'cpu_usage'?" {
+		t.Errorf("Unexpected field error: %v", err)
+	}
+}
+
+func TestSuggestionsInParseErrors(t *testing.T) {
+	cmd, err := NewCommand(&TestCompletionConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	_, err = cmd.Parse([]string{"+nmae", "x", "-type", "lien"})
+	if err == nil {
+		t.Fatal("Expected parse errors")
+	}
+	for _, want := range []string{
+		"unknown flag: +nmae; did you mean +name?",
+		"invalid value 'lien', must be one of: bar, line, area; did you mean 'line'?",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestFieldValidationAgainstFile(t *testing.T) {
+	cmd, err := NewCommand(&TestCompletionConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	cmd.SetValidateFields(true)
+
+	file := "../examples/chart/testdata/sample.tsv"
+	if _, err := cmd.Parse([]string{file, "-field", "time", "-match", "cpu_usage", "3"}); err != nil {
+		t.Errorf("Unexpected error for known fields: %v", err)
+	}
+
+	_, err = cmd.Parse([]string{file, "-field", "tiem", "+match", "cpu_usge", "3"})
+	var argErrs ArgumentErrors
+	if !errors.As(err, &argErrs) || len(argErrs.Errors) != 2 {
+		t.Fatalf("Expected two field errors, got %v", err)
+	}
+	for i, want := range []struct {
+		position int
+		message  string
+	}{
+		{3, "unknown field 'tiem' in " + file + "; did you mean 'time'?"},
+		{5, "unknown field 'cpu_usge' in " + file + "; did you mean 'cpu_usage'?"},
+	} {
+		var parseErr ParseError
+		if !errors.As(argErrs.Errors[i], &parseErr) || parseErr.Position != want.position || parseErr.Message != want.message {
+			t.Errorf("Error %d: expected %q at %d, got %+v", i, want.message, want.position, argErrs.Errors[i])
+		}
+	}
+}
+
+func TestFieldValidationAgainstStdin(t *testing.T) {
+	cmd, err := NewCommand(&TestCompletionConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	cmd.SetValidateFields(true)
+	cmd.stdin = strings.NewReader("time\tcpu_usage\n1\t25\n")
+	cmd.cacheDir = t.TempDir()
+
+	_, err = cmd.Parse([]string{"-field", "cpu"})
+	if err == nil || !strings.Contains(err.Error(), "unknown field 'cpu' in stdin") {
+		t.Errorf("Expected unknown field error for stdin, got %v", err)
+	}
+
+	// The header read for validation must be replayed to the Commander
+	input, err := OpenInput(withCommand(context.Background(), cmd), "-")
+	if err != nil {
+		t.Fatalf("OpenInput failed: %v", err)
+	}
+	data, err := io.ReadAll(input)
+	if err != nil {
+		t.Fatalf("Reading input failed: %v", err)
+	}
+	if string(data) != "time\tcpu_usage\n1\t25\n" {
+		t.Errorf("Expected stdin to be replayed in full, got %q", data)
+	}
+}
+
+func TestFieldValidationSkipsOtherInputs(t *testing.T) {
+	cmd, err := NewCommand(&bindConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	cmd.SetValidateFields(true)
+	stdin := strings.NewReader("time\tcpu_usage\n1\t25\n")
+	cmd.stdin = stdin
+	cmd.cacheDir = t.TempDir()
+
+	// A named input that isn't TSV is left to the Commander, and stdin unread
+	for _, args := range [][]string{{"-argv", "data.txt", "-y", "cpu"}, {"notes.txt", "-y", "cpu"}} {
+		if _, err := cmd.Parse(args); err != nil {
+			t.Errorf("Parse(%v) failed: %v", args, err)
+		}
+	}
+	if stdin.Len() != len("time\tcpu_usage\n1\t25\n") {
+		t.Errorf("Expected stdin to be left unread, %d bytes remain", stdin.Len())
+	}
+
+	if _, err := cmd.Parse([]string{"-argv", "-", "-y", "cpu"}); err == nil || !strings.Contains(err.Error(), "in stdin") {
+		t.Errorf("Expected -argv - to validate against stdin, got %v", err)
+	}
+}
+
+func TestPipedInput(t *testing.T) {
+	t.Setenv("GS_HEADER", "")
+	t.Setenv("GS_RECORD_STDIN", "1")
+	cacheDir := t.TempDir()
+	input := "host\tcpu\nweb1\t25\nweb2\t40\ndb1\t90\n"
+
+	cmd, err := NewCommand(&TestCompletionConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	cmd.cacheDir = cacheDir
+	cmd.stdin = strings.NewReader(input)
+
+	header, rows, err := cmd.PeekStdin(2)
+	if err != nil {
+		t.Fatalf("PeekStdin failed: %v", err)
+	}
+	if !reflect.DeepEqual([]string(header), []string{"host", "cpu"}) || len(rows) != 2 || rows[1][0] != "web2" {
+		t.Errorf("Unexpected peek: %q %q", header, rows)
+	}
+	if _, rows, _ := cmd.PeekStdin(1); len(rows) != 1 {
+		t.Errorf("Expected a shorter peek to return 1 row, got %d", len(rows))
+	}
+
+	replayed, err := OpenInput(withCommand(context.Background(), cmd), "")
+	if err != nil {
+		t.Fatalf("OpenInput failed: %v", err)
+	}
+	data, _ := io.ReadAll(replayed)
+	if string(data) != input {
+		t.Errorf("Expected stdin to be replayed in full, got %q", data)
+	}
+	replayed.Close()
+
+	// A later invocation completes from the sidecar the piped run recorded
+	completer, _ := NewCommand(&TestCompletionConfig{})
+	completer.cacheDir = cacheDir
+	completions, err := completer.complete([]string{"-field", ""}, 1)
+	if err != nil || !reflect.DeepEqual(completions, []string{"host", "cpu"}) {
+		t.Errorf("Expected sidecar field completions, got %v (%v)", completions, err)
+	}
+	completions, err = completer.complete([]string{"-match", "host", "w"}, 2)
+	if err != nil || !reflect.DeepEqual(completions, []string{"web1", "web2"}) {
+		t.Errorf("Expected sidecar content completions, got %v (%v)", completions, err)
+	}
+
+	// Sidecars are only used while recording is on, and expire
+	sidecar := completer.headerSidecar()
+	t.Setenv("GS_RECORD_STDIN", "")
+	if path, _ := completer.pipedInput(); path != "" {
+		t.Errorf("Expected no sidecar without GS_RECORD_STDIN, got %s", path)
+	}
+	t.Setenv("GS_RECORD_STDIN", "1")
+	old := time.Now().Add(-2 * sidecarMaxAge)
+	os.Chtimes(sidecar, old, old)
+	if path, _ := completer.pipedInput(); path != "" {
+		t.Errorf("Expected an expired sidecar to be ignored, got %s", path)
+	}
+	if _, err := os.Stat(sidecar); !os.IsNotExist(err) {
+		t.Errorf("Expected an expired sidecar to be removed, got %v", err)
+	}
+
+	// Without recording nothing is written
+	t.Setenv("GS_RECORD_STDIN", "")
+	cmd.stdinPeek = nil
+	cmd.stdin = strings.NewReader(input)
+	unrecorded, _ := OpenInput(withCommand(context.Background(), cmd), "")
+	io.ReadAll(unrecorded)
+	unrecorded.Close()
+	if _, err := os.Stat(sidecar); !os.IsNotExist(err) {
+		t.Errorf("Expected no sidecar without GS_RECORD_STDIN, got %v", err)
+	}
+
+	// GS_HEADER takes precedence, and stands in for a missing stdin header
+	t.Setenv("GS_HEADER", "time,cpu_usage")
+	completions, _ = completer.complete([]string{"-field", "c"}, 1)
+	if !reflect.DeepEqual(completions, []string{"cpu_usage"}) {
+		t.Errorf("Expected GS_HEADER field completions, got %v", completions)
+	}
+
+	completer.SetValidateFields(true)
+	completer.stdin = strings.NewReader("")
+	_, err = completer.Parse([]string{"-field", "cpu"})
+	if err == nil || !strings.Contains(err.Error(), "unknown field 'cpu' in GS_HEADER") {
+		t.Errorf("Expected validation against GS_HEADER, got %v", err)
+	}
+}
+
+type filterConfig struct {
+	Match []struct{ Field, Pattern string } `gs:"multi,local,list,args=field:content"`
+	Y     []string                          `gs:"field,local,list"`
+}
+
+// globalFilterConfig has a global and a default, which Parse copies into
+// every clause
+type globalFilterConfig struct {
+	Title string                            `gs:"string,global,last"`
+	Width int                               `gs:"number,global,last,default=800"`
+	Match []struct{ Field, Pattern string } `gs:"multi,local,list,args=field:content"`
+	Y     []string                          `gs:"field,local,list"`
+}
+
+func TestFilter(t *testing.T) {
+	cmd, err := NewCommand(&filterConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	header := []string{"host", "status"}
+	rows := [][]string{
+		{"web1", "ok"},
+		{"web2", "down"},
+		{"db1", "ok"},
+		{"db2"},
+	}
+	predicates := map[string]Predicate{"Match": MatchPredicate}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string // Hosts of the selected rows
+	}{
+		{"no predicates", []string{"-y", "status"}, []string{"web1", "web2", "db1", "db2"}},
+		{"and within a clause", []string{"-match", "host", "^web", "-match", "status", "ok"}, []string{"web1"}},
+		{"or across clauses", []string{"-match", "host", "web1", "-", "-match", "host", "db"}, []string{"web1", "db1", "db2"}},
+		{"negated switch", []string{"-match", "host", "^web", "+match", "status", "ok"}, []string{"web2"}},
+		{"negated clause", []string{"+", "-match", "status", "ok"}, []string{"web2", "db2"}},
+		{"negated clause after a clause", []string{"-match", "host", "^web", "+", "-match", "host", "^db"}, []string{"web1", "web2"}},
+		{"missing cells are empty", []string{"-match", "status", "^$"}, []string{"db2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clauses, err := cmd.Parse(test.args)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			filter, err := NewFilter(clauses, predicates, header)
+			if err != nil {
+				t.Fatalf("NewFilter failed: %v", err)
+			}
+
+			var hosts []string
+			for _, row := range filter.Rows(rows) {
+				hosts = append(hosts, row[0])
+			}
+			if !reflect.DeepEqual(hosts, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, hosts)
+			}
+		})
+	}
+
+	// Globals, defaults and the input file fill every clause, but only
+	// predicates select rows
+	withGlobals, err := NewCommand(&globalFilterConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	clauses, err := withGlobals.Parse([]string{"data.tsv", "-title", "t", "-y", "status", "+", "-match", "status", "ok"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	filter, err := NewFilter(clauses, predicates, header)
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+	if selected := filter.Rows(rows); len(selected) != 2 || selected[0][0] != "web2" || selected[1][0] != "db2" {
+		t.Errorf("Expected only the negated clause to select rows, got %v", selected)
+	}
+
+	clauses, err = cmd.Parse([]string{"-match", "host", "web", "-", "-match", "host", "db"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	filter, err = NewFilter(clauses, predicates, header)
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+	if len(filter.ClauseRows(1, rows)) != 2 || !filter.MatchClause(0, rows[1]) || filter.MatchClause(1, rows[1]) {
+		t.Errorf("Unexpected per-clause matches")
+	}
+
+	// A negated clause without predicates still selects every row for its series
+	clauses, err = cmd.Parse([]string{"-y", "status", "+", "-y", "host"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	filter, err = NewFilter(clauses, predicates, header)
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+	if len(filter.ClauseRows(0, rows)) != len(rows) || len(filter.ClauseRows(1, rows)) != len(rows) {
+		t.Errorf("Expected every row for clauses without predicates, got %v and %v",
+			filter.ClauseRows(0, rows), filter.ClauseRows(1, rows))
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	cmd, err := NewCommand(&filterConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	clauses, err := cmd.Parse([]string{"-match", "hots", "web", "-", "-match", "host", "("})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	_, err = NewFilter(clauses, map[string]Predicate{"Match": MatchPredicate}, []string{"host", "status"})
+	if err == nil {
+		t.Fatalf("Expected errors for an unknown field and a bad pattern")
+	}
+
+	var validation ValidationError
+	if !errors.As(err, &validation) || validation.Field != "-match" || validation.Clause != 1 {
+		t.Errorf("Expected a ValidationError for -match in clause 1, got %v", err)
+	}
+	for _, want := range []string{"did you mean 'host'?", "clause 2: invalid pattern '('"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err.Error())
+		}
+	}
+}
+
+type predicateConfig struct {
+	Predicates
+	Y []string `gs:"field,local,list"`
+}
+
+func TestStandardPredicates(t *testing.T) {
+	cmd, err := NewCommand(&predicateConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	header := []string{"host", "cpu"}
+	rows := [][]string{
+		{"web1", "25"},
+		{"web2", "40"},
+		{"db1", "NA"},
+		{"db2", " 90 "},
+	}
+
+	tests := []struct {
+		args     []string
+		expected []string // Hosts of the selected rows
+	}{
+		{[]string{"-gt", "cpu", "30"}, []string{"web2", "db2"}},
+		{[]string{"-ge", "cpu", "40", "-lt", "cpu", "90"}, []string{"web2"}},
+		{[]string{"-le", "cpu", "25", "-", "-eq", "cpu", "90"}, []string{"web1", "db2"}},
+		{[]string{"-ne", "cpu", "25"}, []string{"web2", "db2"}},
+		{[]string{"+eq", "cpu", "25"}, []string{"web2", "db1", "db2"}},
+		{[]string{"-range", "cpu", "20", "40"}, []string{"web1", "web2"}},
+		{[]string{"-in", "host", "web1, db1"}, []string{"web1", "db1"}},
+		{[]string{"-null", "cpu"}, []string{"db1"}},
+		{[]string{"+null", "cpu", "-lt", "cpu", "50"}, []string{"web1", "web2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			clauses, err := cmd.Parse(test.args)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			filter, err := NewFilter(clauses, StandardPredicates, header)
+			if err != nil {
+				t.Fatalf("NewFilter failed: %v", err)
+			}
+
+			var hosts []string
+			for _, row := range filter.Rows(rows) {
+				hosts = append(hosts, row[0])
+			}
+			if !reflect.DeepEqual(hosts, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, hosts)
+			}
+		})
+	}
+
+	config := &predicateConfig{}
+	cmd, _ = NewCommand(config)
+	if _, err := cmd.Parse([]string{"-range", "cpu", "10", "20", "-gt", "cpu", "5"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !reflect.DeepEqual(config.Range, []Interval{{Field: "cpu", Low: 10, High: 20}}) || config.Gt[0].Value != 5 {
+		t.Errorf("Unexpected bindings: %+v %+v", config.Range, config.Gt)
+	}
+
+	if _, err := cmd.Parse([]string{"-gt", "cpu", "high"}); err == nil || !strings.Contains(err.Error(), "invalid number 'high'") {
+		t.Errorf("Expected a number error, got %v", err)
+	}
+	clauses, _ := cmd.Parse([]string{"-range", "cpu", "20", "10"})
+	if _, err := NewFilter(clauses, StandardPredicates, header); err == nil || !strings.Contains(err.Error(), "empty range") {
+		t.Errorf("Expected an empty range error, got %v", err)
+	}
+}
+
+func TestPredicateCompletion(t *testing.T) {
+	cmd, err := NewCommand(&predicateConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	file := "../examples/chart/testdata/sample.tsv"
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{file, "-gt", ""}, []string{"time", "cpu_usage", "memory_usage", "disk_io", "network_rx"}},
+		{[]string{file, "-gt", "cpu_usage", "3"}, []string{"30", "35"}},
+		{[]string{file, "-range", "cpu_usage", "25", "4"}, []string{"40", "45"}},
+		{[]string{file, "+in", "cpu_usage", ""}, []string{"25", "30", "35", "40", "45"}},
+	}
+
+	for _, test := range tests {
+		completions, err := cmd.complete(test.args, len(test.args)-1)
+		if err != nil {
+			t.Fatalf("Completion failed: %v", err)
+		}
+		if !reflect.DeepEqual(completions, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.args, test.expected, completions)
+		}
+	}
+
+	if !strings.Contains(cmd.GenerateHelp(), "-range <field> <low> <high>") {
+		t.Errorf("Expected named -range arguments in help")
+	}
+	if _, err := parseArgumentSpecs("field:number:number"); err == nil {
+		t.Errorf("Expected an error for duplicate argument names")
+	}
+}
+
+func TestCompressedInputCompletion(t *testing.T) {
+	cmd, err := NewCommand(&TestCompletionConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+
+	file := "tsv/testdata/sample.tsv.bz2"
+	if found := cmd.findTSVFile([]string{"-name", "x", file}); found != file {
+		t.Errorf("Expected findTSVFile to find %s, got %q", file, found)
+	}
+
+	completions, err := cmd.complete([]string{file, "-field", ""}, 2)
+	if err != nil || !reflect.DeepEqual(completions, []string{"time", "cpu"}) {
+		t.Errorf("Expected fields from the compressed file, got %v (%v)", completions, err)
+	}
+	completions, err = cmd.complete([]string{file, "-match", "cpu", ""}, 3)
+	if err != nil || !reflect.DeepEqual(completions, []string{"25", "35"}) {
+		t.Errorf("Expected values from the compressed file, got %v (%v)", completions, err)
+	}
+
+	for _, pattern := range []string{".tsv", ".[tc]sv", ".{tsv,csv}", ".bz2"} {
+		if !matchesSuffixPattern("sample.tsv.bz2", pattern) {
+			t.Errorf("Expected sample.tsv.bz2 to match %s", pattern)
+		}
+	}
+	if matchesSuffixPattern("sample.json.gz", ".tsv") {
+		t.Errorf("Expected sample.json.gz not to match .tsv")
+	}
+
+	input, err := OpenInput(context.Background(), file)
+	if err != nil {
+		t.Fatalf("OpenInput failed: %v", err)
+	}
+	defer input.Close()
+	if data, _ := io.ReadAll(input); string(data) != "time\tcpu\n1\t25\n2\t35\n" {
+		t.Errorf("Expected OpenInput to decompress, got %q", data)
+	}
+}
+
+func TestPersistentCompletionCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	file := filepath.Join(t.TempDir(), "data.tsv")
+	if err := os.WriteFile(file, []byte("host\tcpu\nweb1\t25\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each -complete is a new process, so use a new command for each lookup
+	complete := func(args ...string) []string {
+		cmd, err := NewCommand(&TestCompletionConfig{})
+		if err != nil {
+			t.Fatalf("Failed to create command: %v", err)
+		}
+		cmd.SetCacheDir(cacheDir)
+		completions, err := cmd.complete(args, len(args)-1)
+		if err != nil {
+			t.Fatalf("Completion failed: %v", err)
+		}
+		return completions
+	}
+
+	complete(file, "-field", "")
+	if got := complete(file, "-match", "cpu", ""); !reflect.DeepEqual(got, []string{"25"}) {
+		t.Fatalf("Expected [25], got %v", got)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(cacheDir, "completion", "*.json")); len(entries) != 1 {
+		t.Fatalf("Expected one cache entry, got %v", entries)
+	}
+
+	// A cached entry is used while the file is unchanged...
+	cmd, _ := NewCommand(&TestCompletionConfig{})
+	cmd.SetCacheDir(cacheDir)
+	entry := cmd.cachedEntry(file, false)
+	if entry == nil || !reflect.DeepEqual(entry.Fields, []string{"host", "cpu"}) || !reflect.DeepEqual(entry.Values["cpu"], []string{"25"}) {
+		t.Fatalf("Unexpected cache entry %+v", entry)
+	}
+	entry.Values["cpu"] = []string{"cached"}
+	cmd.updateCache(file, func(e *cacheEntry) { *e = *entry })
+	if got := complete(file, "-match", "cpu", ""); !reflect.DeepEqual(got, []string{"cached"}) {
+		t.Errorf("Expected the cached values, got %v", got)
+	}
+
+	// ...and rebuilt once it changes
+	later := time.Now().Add(time.Minute)
+	os.WriteFile(file, []byte("host\tcpu\nweb1\t30\n"), 0o644)
+	os.Chtimes(file, later, later)
+	if got := complete(file, "-match", "cpu", ""); !reflect.DeepEqual(got, []string{"30"}) {
+		t.Errorf("Expected values from the changed file, got %v", got)
+	}
+
+	// Large files that have only grown in place are completed from the cache
+	info, _ := os.Stat(file)
+	if fileInode(info) != 0 {
+		large := filepath.Join(filepath.Dir(file), "large.tsv")
+		rows := strings.Repeat("1\t2\n", cacheStaleSize/4)
+		writeLarge := func(flag int, data string) {
+			t.Helper()
+			f, err := os.OpenFile(large, flag|os.O_WRONLY|os.O_CREATE, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString(data)
+			f.Close()
+		}
+		writeLarge(os.O_TRUNC, "old_a\told_b\n"+rows)
+		complete(large, "-field", "")
+		writeLarge(os.O_APPEND, "3\t4\n")
+		if entry := cmd.cachedEntry(large, true); entry == nil || !entry.appended {
+			t.Errorf("Expected a large appended file to use the cache for completion")
+		}
+		if cmd.cachedEntry(large, false) != nil {
+			t.Errorf("Expected an appended file to be rescanned for validation")
+		}
+
+		// but not once rewritten in place, even if it grew
+		writeLarge(os.O_TRUNC, "new_a\tnew_b\n"+rows+rows)
+		if got := complete(large, "-field", ""); !reflect.DeepEqual(got, []string{"new_a", "new_b"}) {
+			t.Errorf("Expected the fields of the rewritten file, got %v", got)
+		}
+		validating, _ := NewCommand(&TestCompletionConfig{})
+		validating.SetCacheDir(cacheDir)
+		validating.SetValidateFields(true)
+		if _, err := validating.Parse([]string{large, "-field", "new_a"}); err != nil {
+			t.Errorf("Expected the rewritten header to validate: %v", err)
+		}
+
+		stale := &cacheEntry{Size: 10, Inode: fileInode(info), Prefix: "x"}
+		stale.Path, _ = filepath.Abs(file)
+		if stale.appendedTo(file, grownFile{info, 20}) {
+			t.Errorf("Expected a small appended file to be rescanned")
+		}
+	}
+
+	// The cache is bounded, evicting the least recently used entries
+	dir := filepath.Join(cacheDir, "completion")
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, string(rune('a'+i))+".json")
+		os.WriteFile(path, []byte("{}"), 0o644)
+		used := time.Now().Add(time.Duration(i-10) * time.Hour)
+		os.Chtimes(path, used, used)
+	}
+	evictCache(dir, 3)
+	if entries, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(entries) != 3 {
+		t.Errorf("Expected 3 entries after eviction, got %v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected the least recently used entry to be evicted")
+	}
+
+	// Concurrent updates of one entry are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(field string) {
+			defer wg.Done()
+			cmd, _ := NewCommand(&TestCompletionConfig{})
+			cmd.SetCacheDir(cacheDir)
+			cmd.updateCache(file, func(e *cacheEntry) {
+				if e.Values == nil {
+					e.Values = make(map[string][]string)
+				}
+				e.Values[field] = []string{field}
+			})
+		}(fmt.Sprint("field", i))
+	}
+	wg.Wait()
+	if entry := cmd.cachedEntry(file, false); fileInode(info) != 0 && (entry == nil || len(entry.Values) < 20) {
+		t.Errorf("Expected every concurrent update in the entry, got %+v", entry)
+	}
+
+	cmd.SetCacheDir("")
+	if cmd.cachePath(file) != "" {
+		t.Errorf("Expected caching to be disabled")
+	}
+}
+
+// grownFile reports a different size for a file
+type grownFile struct {
+	os.FileInfo
+	size int64
+}
+
+func (f grownFile) Size() int64 { return f.size }
+
+type sampleConfig struct {
+	Match []struct{ Field, Content string } `gs:"multi,local,list,args=field:content"`
+	Level []struct{ Field, Content string } `gs:"multi,local,list,args=field:content,sample=5"`
+}
+
+func TestContentSampling(t *testing.T) {
+	t.Setenv("GS_SCAN_DEPTH", "")
+	t.Setenv("GS_SCAN_BUDGET", "")
+
+	// A time-ordered log whose later rows have values the first 100 lack
+	var data strings.Builder
+	data.WriteString("time\tlevel\n")
+	for i := 0; i < 5000; i++ {
+		level := "info"
+		switch {
+		case i >= 4000:
+			level = "error"
+		case i%10 == 0:
+			level = "debug"
+		}
+		data.WriteString(time.Unix(int64(i), 0).UTC().Format(time.RFC3339) + "\t" + level + "\n")
+	}
+	file := filepath.Join(t.TempDir(), "log.tsv")
+	if err := os.WriteFile(file, []byte(data.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	complete := func(configure func(*GSCommand), args ...string) []string {
+		cmd, err := NewCommand(&sampleConfig{})
+		if err != nil {
+			t.Fatalf("Failed to create command: %v", err)
+		}
+		cmd.SetCacheDir("")
+		if configure != nil {
+			configure(cmd)
+		}
+		completions, err := cmd.complete(append([]string{file}, args...), len(args))
+		if err != nil {
+			t.Fatalf("Completion failed: %v", err)
+		}
+		return completions
+	}
+
+	// The whole file is sampled, and values are ranked by frequency
+	if got := complete(nil, "-match", "level", ""); !reflect.DeepEqual(got, []string{"info", "error", "debug"}) {
+		t.Errorf("Expected frequency-ranked values from the whole file, got %v", got)
+	}
+
+	// The time budget stops reading early
+	budget := func(cmd *GSCommand) { cmd.SetScanBudget(time.Nanosecond) }
+	if got := complete(budget, "-match", "level", ""); !reflect.DeepEqual(got, []string{"info", "debug"}) {
+		t.Errorf("Expected only early values within the budget, got %v", got)
+	}
+	t.Setenv("GS_SCAN_BUDGET", "1ns")
+	if got := complete(nil, "-match", "level", ""); !reflect.DeepEqual(got, []string{"info", "debug"}) {
+		t.Errorf("Expected GS_SCAN_BUDGET to limit reading, got %v", got)
+	}
+	t.Setenv("GS_SCAN_BUDGET", "")
+
+	// Depth comes from GS_SCAN_DEPTH, then the sample= tag, then SetScanDepth
+	cmd, _ := NewCommand(&sampleConfig{})
+	level, match := &cmd.fields[1], &cmd.fields[0]
+	cmd.SetScanDepth(50)
+	if cmd.sampleDepth(match) != 50 || cmd.sampleDepth(level) != 5 {
+		t.Errorf("Expected depths 50 and 5, got %d and %d", cmd.sampleDepth(match), cmd.sampleDepth(level))
+	}
+	t.Setenv("GS_SCAN_DEPTH", "7")
+	if cmd.sampleDepth(match) != 7 || cmd.sampleDepth(level) != 7 {
+		t.Errorf("Expected GS_SCAN_DEPTH to override, got %d and %d", cmd.sampleDepth(match), cmd.sampleDepth(level))
+	}
+
+	if _, err := NewCommand(&struct {
+		Match []string `gs:"multi,local,list,args=field:content,sample=none"`
+	}{}); err == nil {
+		t.Errorf("Expected an error for an invalid sample size")
+	}
+}
+
+type kindConfig struct {
+	Y     []string `gs:"field,local,list,kind=numeric,help=Y axis"`
+	Label string   `gs:"field,global,last,kind=text,help=Label"`
+	Predicates
+}
+
+func (c *kindConfig) Execute(ctx context.Context, clauses []ClauseSet) error {
+	return nil
+}
+
+func TestColumnKinds(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.tsv")
+	content := "time\thost\tcpu\tload\n1\tweb\t25\t0.5\n2\tweb\tNA\t1.5\n3\tdb\t35\t0.25\n4\tdb\t45\t2\n"
+	if err := os.WriteFile(data, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := NewCommand(&kindConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	cmd.SetCacheDir(t.TempDir())
+
+	kinds, err := cmd.ColumnKinds(data)
+	if err != nil {
+		t.Fatalf("ColumnKinds failed: %v", err)
+	}
+	want := map[string]tsv.Kind{"time": tsv.Int, "host": tsv.Enum, "cpu": tsv.Int, "load": tsv.Float}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("Expected kinds %v, got %v", want, kinds)
+	}
+
+	// Only columns of the switch's kind are completed
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{data, "-y", ""}, []string{"time", "cpu", "load"}},
+		{[]string{data, "-label", ""}, []string{"host"}},
+		{[]string{data, "-gt", ""}, []string{"time", "cpu", "load"}},
+		{[]string{data, "-in", ""}, []string{"time", "host", "cpu", "load"}},
+	} {
+		completions, err := cmd.complete(tt.args, 2)
+		if err != nil || !slices.Equal(completions, tt.want) {
+			t.Errorf("Completing %v: expected %v, got %v (%v)", tt.args[1:], tt.want, completions, err)
+		}
+	}
+
+	// The kinds are cached with the other completion data
+	fresh, _ := NewCommand(&kindConfig{})
+	fresh.SetCacheDir(cmd.cacheDir)
+	if entry := fresh.cachedEntry(data, false); entry == nil || !reflect.DeepEqual(entry.Kinds, want) {
+		t.Errorf("Expected kinds in the cache entry, got %+v", entry)
+	}
+
+	// Validation rejects columns of the wrong kind up front
+	cmd.SetValidateFields(true)
+	if _, err := cmd.Parse([]string{data, "-y", "cpu", "-gt", "load", "1"}); err != nil {
+		t.Errorf("Unexpected error for numeric columns: %v", err)
+	}
+	_, err = cmd.Parse([]string{data, "-y", "host"})
+	if err == nil || !strings.Contains(err.Error(), "field 'host' in "+data+" is enum, not numeric") {
+		t.Errorf("Expected a kind error for -y host, got %v", err)
+	}
+
+	// Standard input is peeked
+	cmd.stdin = strings.NewReader("when\tok\n2024-01-02\ttrue\n2024-01-03\tfalse\n")
+	kinds, err = cmd.ColumnKinds("-")
+	if err != nil || kinds["when"] != tsv.Timestamp || kinds["ok"] != tsv.Bool {
+		t.Errorf("Unexpected stdin kinds %v (%v)", kinds, err)
+	}
+
+	if _, err := parseFieldTag("Y", "field,local,list,kind=number"); err == nil || !strings.Contains(err.Error(), "invalid kind: number") {
+		t.Errorf("Expected an invalid kind error, got %v", err)
+	}
+}
+
+// groupConfig is a subcommand recording the clauses it is run with
+type groupConfig struct {
+	Summary string
+	Field   string                   `gs:"field,global,last,help=Field name"`
+	Match   []map[string]interface{} `gs:"multi,local,list,args=field:content,help=Match conditions"`
+	clauses []ClauseSet
+}
+
+func (gc *groupConfig) Execute(ctx context.Context, clauses []ClauseSet) error {
+	gc.clauses = clauses
+	return nil
+}
+
+func (gc *groupConfig) Validate() error {
+	return nil
+}
+
+func (gc *groupConfig) Documentation() Documentation {
+	return Documentation{Name: "tsv" + gc.Summary, Summary: gc.Summary}
+}
+
+func TestGroup(t *testing.T) {
+	group := NewGroup(Documentation{Name: "tsv", Summary: "TSV tools"})
+	chart := &groupConfig{Summary: "Plot columns"}
+	sel := &groupConfig{Summary: "Select rows"}
+	if _, err := group.Add("chart", chart); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	selectCmd, err := group.Add("select", sel)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := group.Add("chart", &groupConfig{}); err == nil {
+		t.Errorf("Expected an error for a duplicate subcommand")
+	}
+	if _, err := group.Add("-x", &groupConfig{}); err == nil {
+		t.Errorf("Expected an error for an invalid subcommand name")
+	}
+	if group.Lookup("select") != selectCmd {
+		t.Errorf("Lookup did not return the select subcommand")
+	}
+
+	// Each subcommand parses its own clauses
+	if err := group.Execute(context.Background(), []string{"select", "-match", "host", "web", "+", "-match", "cpu", "9"}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(sel.clauses) != 2 || !sel.clauses[1].IsNegated || chart.clauses != nil {
+		t.Errorf("Expected two clauses for select only, got %+v", sel.clauses)
+	}
+
+	err = group.Execute(context.Background(), []string{"chrat"})
+	if err == nil || err.Error() != "unknown command 'chrat'; did you mean 'chart'?" {
+		t.Errorf("Expected an unknown command error, got %v", err)
+	}
+	if err := group.Execute(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "chart, select") {
+		t.Errorf("Expected a missing command error, got %v", err)
+	}
+
+	// Completion covers the subcommand names and then each subcommand
+	candidates, err := group.completeDescribed([]string{""}, 0)
+	if err != nil || len(candidates) != 2 || candidates[0] != (Candidate{Value: "chart", Kind: "command", Description: "Plot columns"}) {
+		t.Errorf("Unexpected subcommand candidates %v (%v)", candidates, err)
+	}
+	candidates, err = group.completeDescribed([]string{"select", "-mat"}, 1)
+	if err != nil || len(candidates) != 1 || candidates[0].Value != "-match" {
+		t.Errorf("Expected -match from select, got %v (%v)", candidates, err)
+	}
+	var out strings.Builder
+	if err := writeBashCandidates(&out, "tsv se", 6, group.completeDescribed); err != nil || out.String() != "select\tcommand\tSelect rows\n" {
+		t.Errorf("Unexpected bash candidates %q (%v)", out.String(), err)
+	}
+
+	help := group.GenerateHelp()
+	for _, want := range []string{"Usage: tsv <command> [arguments]", "  chart   Plot columns", "  select  Select rows", "tsv <command> -help"} {
+		if !strings.Contains(help, want) {
+			t.Errorf("Group help missing %q:\n%s", want, help)
+		}
+	}
+	if help := selectCmd.GenerateHelp(); !strings.HasPrefix(help, "Usage: tsv select [options]") {
+		t.Errorf("Expected subcommand help to be named after the group:\n%s", help)
+	}
+
+	man := group.GenerateManPage()
+	for _, want := range []string{".TH TSV 1", "tsv \\- TSV tools", ".SH COMMANDS", ".SS chart", ".B tsv select", "\\fB\\-match\\fR \\fIfield\\fR", ".SH CLAUSES"} {
+		if !strings.Contains(man, want) {
+			t.Errorf("Group man page missing %q:\n%s", want, man)
+		}
+	}
+
+	if script := selectCmd.generateBashCompletion(); !strings.Contains(script, "complete -F _tsv_completion tsv") {
+		t.Errorf("Expected a subcommand's completion script to be the group's:\n%s", script)
+	}
+}
+
+func TestGroupAliases(t *testing.T) {
+	group := NewGroup(Documentation{Name: "tsv"})
+	chart := &groupConfig{Summary: "Plot columns"}
+	if _, err := group.Add("chart", chart, "tsv2chart", "chart2"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := group.Add("select", &groupConfig{}, "tsv2chart"); err == nil {
+		t.Errorf("Expected an error for a duplicate alias")
+	}
+	if _, err := group.Add("join", &groupConfig{}, "bin/tsvjoin"); err == nil {
+		t.Errorf("Expected an error for an alias with a path")
+	}
+
+	if help := group.GenerateHelp(); !strings.Contains(help, "Plot columns (also tsv2chart, chart2)") {
+		t.Errorf("Expected aliases in group help:\n%s", help)
+	}
+	if man := group.GenerateManPage(); !strings.Contains(man, "Also installed as \\fBtsv2chart\\fR, \\fBchart2\\fR.") {
+		t.Errorf("Expected aliases in the man page:\n%s", man)
+	}
+
+	// The group's scripts register every alias
+	var script strings.Builder
+	for _, name := range group.scriptNames() {
+		script.WriteString(bashCompletionScript(name))
+	}
+	for _, want := range []string{"complete -F _tsv_completion tsv", "complete -F _tsv2chart_completion tsv2chart", "chart2 -complete-bash"} {
+		if !strings.Contains(script.String(), want) {
+			t.Errorf("Bash completion missing %q", want)
+		}
+	}
+
+	// Invoked as an alias, the subcommand runs on its own
+	if err := group.Run(context.Background(), []string{"/usr/local/bin/tsv2chart", "-field", "time"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(chart.clauses) != 1 {
+		t.Errorf("Expected chart to run, got %+v", chart.clauses)
+	}
+	cmd := group.Lookup("chart")
+	if help := cmd.GenerateHelp(); !strings.HasPrefix(help, "Usage: tsv2chart ") {
+		t.Errorf("Expected help named after the alias:\n%s", help)
+	}
+	if script := cmd.generateBashCompletion(); !strings.Contains(script, "complete -F _tsv2chart_completion tsv2chart") {
+		t.Errorf("Expected the alias's completion script:\n%s", script)
+	}
+
+	// but keeps the settings of the subcommand
+	if name := cmd.configName(); name != "tsv-chart" {
+		t.Errorf("Expected config named tsv-chart under an alias, got %s", name)
+	}
+	if env := cmd.envVar(cmd.fields[0]); env != "TSV_CHART_FIELD" {
+		t.Errorf("Expected TSV_CHART_FIELD under an alias, got %s", env)
+	}
+}
+
+func TestGroupInstall(t *testing.T) {
+	group := NewGroup(Documentation{Name: "tsv"})
+	if _, err := group.Add("chart", &groupConfig{}, "tsv2chart"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "tsv")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	opts := InstallOptions{
+		Binary:  binary,
+		BinDir:  filepath.Join(dir, "bin"),
+		BashDir: filepath.Join(dir, "bash"),
+		ZshDir:  filepath.Join(dir, "zsh"),
+		FishDir: filepath.Join(dir, "fish"),
+	}
+	// Installing twice replaces the symlinks and completion files
+	for range 2 {
+		if err := group.Install(opts); err != nil {
+			t.Fatalf("Install failed: %v", err)
+		}
+	}
+
+	if target, err := os.Readlink(filepath.Join(opts.BinDir, "tsv2chart")); err != nil || target != binary {
+		t.Errorf("Expected tsv2chart to link to %s, got %q (%v)", binary, target, err)
+	}
+	for path, want := range map[string]string{
+		filepath.Join(opts.BashDir, "tsv"):            "complete -F _tsv_completion tsv",
+		filepath.Join(opts.BashDir, "tsv2chart"):      "complete -F _tsv2chart_completion tsv2chart",
+		filepath.Join(opts.ZshDir, "_tsv2chart"):      "#compdef tsv2chart",
+		filepath.Join(opts.FishDir, "tsv2chart.fish"): "complete -c tsv2chart",
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(data), want) {
+			t.Errorf("Expected %s to contain %q (%v)", path, want, err)
+		}
+	}
+
+	// Other files are never replaced by a symlink
+	if err := os.Remove(filepath.Join(opts.BinDir, "tsv2chart")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(opts.BinDir, "tsv2chart"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := group.Install(InstallOptions{Binary: binary, BinDir: opts.BinDir}); err == nil || !strings.Contains(err.Error(), "is not a symlink") {
+		t.Errorf("Expected an error for an existing file, got %v", err)
+	}
+}
+
+// layeredConfig has defaults that config files and the environment override
+type layeredConfig struct {
+	Width   int                      `gs:"number,global,last,help=Width,default=800"`
+	Title   string                   `gs:"string,global,last,help=Title"`
+	Smooth  bool                     `gs:"flag,global,last,help=Smooth lines"`
+	Match   []map[string]interface{} `gs:"multi,local,list,args=field:content,help=Match conditions"`
+	Pattern string                   `gs:"string,local,last,help=Pattern"`
+}
+
+func (lc *layeredConfig) Documentation() Documentation {
+	return Documentation{Name: "mytool"}
+}
+
+func TestLayeredDefaults(t *testing.T) {
+	configDir := t.TempDir()
+	project := t.TempDir()
+	work := filepath.Join(project, "sub")
+	if err := os.Mkdir(work, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(work)
+
+	parse := func(args ...string) (*layeredConfig, error) {
+		t.Helper()
+		config := &layeredConfig{}
+		cmd, err := NewCommand(config)
+		if err != nil {
+			t.Fatalf("Failed to create command: %v", err)
+		}
+		cmd.SetConfigDir(configDir)
+		_, err = cmd.Parse(args)
+		return config, err
+	}
+	expectWidth := func(want int, args ...string) {
+		t.Helper()
+		config, err := parse(args...)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if config.Width != want {
+			t.Errorf("Expected width %d, got %d", want, config.Width)
+		}
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each layer overrides the one before
+	expectWidth(800)
+	write(filepath.Join(configDir, "mytool.toml"), "# defaults\nwidth = 900\nsmooth = true\n")
+	expectWidth(900)
+	write(filepath.Join(project, ".gsrc"), "title = 'Shared' ; for every tool\nother_tool_option = 1\n\n[mytool]\nwidth = \"1000\"\n")
+	expectWidth(1000)
+	t.Setenv("MYTOOL_WIDTH", "1100")
+	expectWidth(1100)
+	expectWidth(1200, "-width", "1200")
+
+	config, err := parse("+smooth")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if config.Title != "Shared" || config.Smooth {
+		t.Errorf("Expected title from .gsrc and +smooth to override the config file, got %+v", config)
+	}
+
+	t.Setenv("COLUMNS", "300") // Keep long paths on one line
+	cmd, _ := NewCommand(&layeredConfig{})
+	cmd.SetConfigDir(configDir)
+	help := cmd.GenerateHelp()
+	for _, want := range []string{
+		"default: 1100 from MYTOOL_WIDTH",
+		"default: Shared from " + filepath.Join(project, ".gsrc"),
+		"MYTOOL_<OPTION> environment variables",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help missing %q:\n%s", want, help)
+		}
+	}
+
+	// JSON is read in place of TOML, and bad values name their source
+	os.Remove(filepath.Join(configDir, "mytool.toml"))
+	os.Remove(filepath.Join(project, ".gsrc"))
+	t.Setenv("MYTOOL_WIDTH", "wide")
+	write(filepath.Join(configDir, "mytool.json"), `{"width": 950, "pattern": "x", "colour": "red", "match": ["a"]}`)
+	_, err = parse()
+	var argErrs ArgumentErrors
+	if !errors.As(err, &argErrs) || len(argErrs.Errors) != 3 {
+		t.Fatalf("Expected 3 errors, got %v", err)
+	}
+	for _, want := range []string{
+		"unknown option 'colour' in " + filepath.Join(configDir, "mytool.json"),
+		"field -match: invalid value in " + filepath.Join(configDir, "mytool.json"),
+		"field -width: invalid number 'wide' (from MYTOOL_WIDTH)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got:\n%v", want, err)
+		}
+	}
+
+	// Invalid defaults the command line overrides are not reported
+	write(filepath.Join(configDir, "mytool.json"), `{"width": 950, "pattern": "x"}`)
+	expectWidth(1200, "-width", "1200")
+
+	// nor are shared keys that don't suit this command
+	os.Unsetenv("MYTOOL_WIDTH")
+	write(filepath.Join(project, ".gsrc"), "width = wide\nsmooth = maybe\n")
+	expectWidth(950)
+}
+
+func TestResponseFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("data.tsv", "host\tstatus\nweb1\t200\n")
+	write("filters.args", "# errors by host\n-match host 'web 1' \\\n  -match status \"5\\\"..\"\n+\n-match host db  # not db\n@more.args\n")
+	write("more.args", "-name \"two\nlines\"\n")
+
+	cmd, err := NewCommand(&TestCompletionConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	clauses, err := cmd.Parse([]string{"data.tsv", "@filters.args", "-type", "line", "-match", "host", "@literal"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(clauses) != 2 || clauses[0].IsNegated || !clauses[1].IsNegated {
+		t.Fatalf("Expected a clause and a negated clause, got %+v", clauses)
+	}
+	if got := fmt.Sprint(clauses[0].Fields["Match"]); got != `[map[content:web 1 field:host] map[content:5".. field:status]]` {
+		t.Errorf("Unexpected first clause matches: %s", got)
+	}
+	if got := fmt.Sprint(clauses[1].Fields["Match"]); got != "[map[content:db field:host] map[content:@literal field:host]]" {
+		t.Errorf("Flag values should not be expanded, got %s", got)
+	}
+	if clauses[1].Fields["Name"] != "two\nlines" || clauses[1].Fields["Type"] != "line" {
+		t.Errorf("Expected nested response file and following flags to apply, got %+v", clauses[1].Fields)
+	}
+
+	// Problems are reported at the response file
+	write("loop.args", "@loop.args")
+	write("bad.args", "-name 'open\n")
+	_, err = cmd.Parse([]string{"@missing.args", "@bad.args", "@loop.args"})
+	var argErrs ArgumentErrors
+	if !errors.As(err, &argErrs) || len(argErrs.Errors) != 3 {
+		t.Fatalf("Expected 3 errors, got %v", err)
+	}
+	for _, want := range []string{"missing.args: no such file", "bad.args:1: unterminated single quote", "does @loop.args include itself?"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got:\n%v", want, err)
+		}
+	}
+
+	// @ words complete as files, and fields come from the input in a response file
+	write("input.args", "data.tsv\n")
+	completions, _ := cmd.complete([]string{"-match", "host", "web", "@fil"}, 3)
+	if !reflect.DeepEqual(completions, []string{"@filters.args"}) {
+		t.Errorf("Expected response file completion, got %v", completions)
+	}
+	completions, _ = cmd.complete([]string{"@input.args", "-field", ""}, 2)
+	if !reflect.DeepEqual(completions, []string{"host", "status"}) {
+		t.Errorf("Expected fields of the input named in a response file, got %v", completions)
+	}
+}
+
+func TestPresets(t *testing.T) {
+	configDir := t.TempDir()
+	project := t.TempDir()
+	t.Chdir(project)
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(configDir, "mytool.json"), `{"width": 900, "presets": {"web": ["-match", "host", "web 1"], "wide": "-width 2000"}}`)
+	write(".gsrc", "[presets]\nerrors = \"-match level ERROR - -match status '5..'\"\nwide = -width 1500\nboth = @@errors - @@web\nloop = -preset loop\n")
+
+	cmd, err := NewCommand(&layeredConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	cmd.SetConfigDir(configDir)
+
+	clauses, err := cmd.Parse([]string{"-preset", "both", "@@wide"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(clauses) != 3 || clauses[1].IsNegated {
+		t.Fatalf("Expected the preset's clauses, got %+v", clauses)
+	}
+	for i, want := range []string{"[map[content:ERROR field:level]]", "[map[content:5.. field:status]]", "[map[content:web 1 field:host]]"} {
+		if got := fmt.Sprint(clauses[i].Fields["Match"]); got != want {
+			t.Errorf("Expected clause %d to match %s, got %s", i, want, got)
+		}
+	}
+	if clauses[2].Fields["Width"] != 1500.0 {
+		t.Errorf("Expected the .gsrc preset to override the config file's, got width %v", clauses[2].Fields["Width"])
+	}
+
+	_, err = cmd.Parse([]string{"-preset", "erors", "@@loop", "-preset"})
+	var argErrs ArgumentErrors
+	if !errors.As(err, &argErrs) || len(argErrs.Errors) != 3 {
+		t.Fatalf("Expected 3 errors, got %v", err)
+	}
+	for _, want := range []string{"unknown preset: erors; did you mean errors?", "does preset loop include itself?", "field -preset: requires a preset name"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got:\n%v", want, err)
+		}
+	}
+
+	// Names complete after -preset or @@, described by their expansion
+	candidates, _ := cmd.completeDescribed([]string{"-preset", "w"}, 1)
+	if len(candidates) != 2 || candidates[0].Value != "web" || candidates[0].Description != `-match host "web 1"` {
+		t.Errorf("Unexpected -preset completion: %+v", candidates)
+	}
+	completions, _ := cmd.complete([]string{"@@e"}, 0)
+	if !reflect.DeepEqual(completions, []string{"@@errors"}) {
+		t.Errorf("Expected @@ completion, got %v", completions)
+	}
+	completions, _ = cmd.complete([]string{"-pre"}, 0)
+	if !reflect.DeepEqual(completions, []string{"-preset"}) {
+		t.Errorf("Expected -preset flag completion, got %v", completions)
+	}
+
+	t.Setenv("COLUMNS", "300")
+	help := cmd.GenerateHelp()
+	for _, want := range []string{"Presets (-preset name or @@name):", "errors", "-match level ERROR - -match status 5.. [from " + filepath.Join(project, ".gsrc")} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help missing %q:\n%s", want, help)
+		}
+	}
+
+	// A command with its own -preset switch keeps it, listing presets as @@name
+	own, err := NewCommand(&presetSwitchConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	own.SetConfigDir(t.TempDir())
+	clauses, err = own.Parse([]string{"-preset", "fast", "@@errors"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(clauses) != 2 || clauses[1].Fields["Preset"] != "fast" {
+		t.Errorf("Expected the -preset switch and @@errors, got %+v", clauses)
+	}
+	if help := own.GenerateHelp(); !strings.Contains(help, "Presets (@@name):") || !strings.Contains(help, "errors") {
+		t.Errorf("Expected presets listed as @@name:\n%s", help)
+	}
+}
+
+// presetSwitchConfig has a -preset switch of its own
+type presetSwitchConfig struct {
+	Preset string                   `gs:"string,global,last,help=Encoder preset"`
+	Match  []map[string]interface{} `gs:"multi,local,list,args=field:content,help=Match conditions"`
+}
+
+func (pc *presetSwitchConfig) Documentation() Documentation {
+	return Documentation{Name: "encoder"}
+}
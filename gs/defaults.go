@@ -0,0 +1,337 @@
+package gs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// projectConfigName is the file of project defaults found by walking up from
+// the working directory
+const projectConfigName = ".gsrc"
+
+// configExtensions are the formats of per-command config files, in the order
+// they are looked for
+var configExtensions = []string{".toml", ".json", ".ini"}
+
+// layeredDefault is the effective default of a field and where it came from
+type layeredDefault struct {
+	value  interface{}
+	source string // A config file or environment variable; "" for the default= tag
+	err    error  // An invalid value, reported only if the field falls back to it
+}
+
+// configSource is a set of values read from a config file
+type configSource struct {
+	path    string
+	values  map[string]interface{}
+	strict  bool                   // Unknown keys are errors rather than options of other commands
+	presets map[string]interface{} // The [presets] section, kept apart from the values
+}
+
+// SetConfigDir sets the directory searched for per-command config files,
+// <dir>/<command>.toml, .json or .ini; "" disables them. It defaults to
+// gogstools under the user config directory ($XDG_CONFIG_HOME or ~/.config on
+// Linux).
+func (cmd *GSCommand) SetConfigDir(dir string) {
+	cmd.configDir = dir
+}
+
+// defaultsHint tells -help readers where defaults can be set
+func (cmd *GSCommand) defaultsHint() string {
+	file := projectConfigName
+	if cmd.configDir != "" {
+		file = filepath.Join(cmd.configDir, cmd.configName()+".toml") + ", " + file
+	}
+	return fmt.Sprintf("Defaults can be set in %s or %s_<OPTION> environment variables.",
+		file, envName(cmd.configName()))
+}
+
+// configName returns the name of the command in config file names, sections,
+// environment variables and the piped input sidecar, e.g. "tsv2chart". A
+// subcommand is "<group>-<name>", e.g. "tsv-chart", whether it is run through
+// the group or an alias, so both share their settings; otherwise like -help
+// it uses the documented name over the binary's.
+func (cmd *GSCommand) configName() string {
+	if cmd.configID != "" {
+		return cmd.configID
+	}
+	return strings.ReplaceAll(cmd.documentation().Name, " ", "-")
+}
+
+// envVar returns the environment variable setting the default of a field,
+// e.g. TSV2CHART_WIDTH
+func (cmd *GSCommand) envVar(meta FieldMeta) string {
+	return envName(cmd.configName() + "_" + parseFlagName(meta.Name)[1:])
+}
+
+// envName upper-cases name and replaces characters not allowed in environment
+// variable names with underscores
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// layeredDefaults returns the effective default of each field that has one.
+// Each layer overrides the one before: the default= tag, the per-command
+// config file, the nearest .gsrc and then the environment; the command line
+// overrides them all. An invalid value becomes the field's default with a
+// ParseError naming its source, so that it is only reported if the command
+// line leaves the field unset; a top-level .gsrc key that is invalid for this
+// command is shared with others and ignored. Unreadable files and unknown
+// keys are returned as errors.
+func (cmd *GSCommand) layeredDefaults() (map[string]layeredDefault, []error) {
+	defaults := make(map[string]layeredDefault)
+	for _, meta := range cmd.fields {
+		if meta.DefaultValue != nil {
+			defaults[meta.Name] = layeredDefault{value: meta.DefaultValue}
+		}
+	}
+
+	sources, errs := cmd.configSources()
+	for _, source := range sources {
+		keys := make([]string, 0, len(source.values))
+		for key := range source.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			raw := source.values[key]
+			meta := cmd.configField(key)
+			if meta == nil {
+				if source.strict {
+					errs = append(errs, ParseError{Message: fmt.Sprintf("unknown option '%s' in %s", key, source.path)})
+				}
+				continue
+			}
+			text, ok := configText(raw)
+			var def layeredDefault
+			if !ok {
+				def = layeredDefault{source: source.path, err: ParseError{
+					Field:   parseFlagName(meta.Name),
+					Message: fmt.Sprintf("invalid value in %s: expected a single value", source.path),
+				}}
+			} else {
+				def = cmd.parseDefault(meta, text, source.path)
+			}
+			if def.err == nil || source.strict {
+				defaults[meta.Name] = def
+			}
+		}
+	}
+
+	for i := range cmd.fields {
+		meta := &cmd.fields[i]
+		if meta.Type == FieldTypeMulti {
+			continue
+		}
+		if text, ok := os.LookupEnv(cmd.envVar(*meta)); ok {
+			defaults[meta.Name] = cmd.parseDefault(meta, text, cmd.envVar(*meta))
+		}
+	}
+
+	return defaults, errs
+}
+
+// parseDefault parses text as the default of meta from source
+func (cmd *GSCommand) parseDefault(meta *FieldMeta, text, source string) layeredDefault {
+	flag := parseFlagName(meta.Name)
+	if meta.Type == FieldTypeMulti {
+		return layeredDefault{source: source, err: ParseError{Field: flag, Message: fmt.Sprintf("multi-argument switches cannot be set in %s", source)}}
+	}
+	value, err := cmd.parseValueWithValidation(text, meta)
+	if err != nil {
+		return layeredDefault{source: source, err: ParseError{Field: flag, Value: text, Message: fmt.Sprintf("%v (from %s)", err, source)}}
+	}
+	return layeredDefault{value: value, source: source}
+}
+
+// configField returns the field set by a config key, the flag name without
+// its dash; underscores may be used for hyphens
+func (cmd *GSCommand) configField(key string) *FieldMeta {
+	flag := "-" + strings.ReplaceAll(strings.ToLower(key), "_", "-")
+	for i := range cmd.fields {
+		if parseFlagName(cmd.fields[i].Name) == flag {
+			return &cmd.fields[i]
+		}
+	}
+	return nil
+}
+
+// configText converts a config value to the text a command line would hold
+func configText(raw interface{}) (string, bool) {
+	switch v := raw.(type) {
+	case string:
+		return v, true
+	case bool, float64, json.Number:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// configSources returns the config files that apply to the command, lowest
+// precedence first: the per-command file, then the command's section and the
+// top-level keys of the nearest .gsrc. Their [presets] sections are kept
+// apart from the defaults.
+func (cmd *GSCommand) configSources() ([]configSource, []error) {
+	var sources []configSource
+	var errs []error
+
+	if cmd.configDir != "" {
+		for _, ext := range configExtensions {
+			path := filepath.Join(cmd.configDir, cmd.configName()+ext)
+			values, err := readConfigFile(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				presets, _ := values[presetSection].(map[string]interface{})
+				delete(values, presetSection)
+				sources = append(sources, configSource{path: path, values: values, strict: true, presets: presets})
+			}
+			break
+		}
+	}
+
+	if path := findProjectConfig(); path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return sources, append(errs, err)
+		}
+
+		// Top-level keys are shared by every command in the project
+		shared := make(map[string]interface{})
+		for key, value := range values {
+			if _, isSection := value.(map[string]interface{}); !isSection {
+				shared[key] = value
+			}
+		}
+		presets, _ := values[presetSection].(map[string]interface{})
+		sources = append(sources, configSource{path: path, values: shared, presets: presets})
+		if section, ok := values[cmd.configName()].(map[string]interface{}); ok {
+			sources = append(sources, configSource{path: path, values: section, strict: true})
+		}
+	}
+
+	return sources, errs
+}
+
+// findProjectConfig returns the nearest .gsrc in the working directory or
+// one of its parents, or ""
+func findProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readConfigFile reads a config file as JSON if it holds an object, and
+// otherwise as INI or the matching subset of TOML: "key = value" lines, with
+// "[name]" starting a section and # or ; starting a comment. Sections are
+// returned as nested maps.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.UseNumber()
+		var values map[string]interface{}
+		if err := decoder.Decode(&values); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		return values, nil
+	}
+
+	values := make(map[string]interface{})
+	current := values
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";"):
+			continue
+		case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+			name := strings.Trim(strings.TrimSpace(text[1:len(text)-1]), `"`)
+			section, ok := values[name].(map[string]interface{})
+			if !ok {
+				section = make(map[string]interface{})
+				values[name] = section
+			}
+			current = section
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, line)
+		}
+		parsed, err := configValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		current[strings.Trim(strings.TrimSpace(key), `"`)] = parsed
+	}
+	return values, scanner.Err()
+}
+
+// configValue parses the value of a "key = value" line: a double-quoted
+// string with escapes, a single-quoted literal string, or bare text up to any
+// trailing comment
+func configValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		end := 1
+		for end < len(value) && value[end] != '"' {
+			if value[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(value) {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return strconv.Unquote(value[:end+1])
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return value[1 : end+1], nil
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	if i := strings.Index(value, " ;"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
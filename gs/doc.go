@@ -0,0 +1,473 @@
+package gs
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Example is a documented example invocation of a command
+type Example struct {
+	Command     string // Command line, e.g. "tsv2chart data.tsv -x time -y cpu_usage"
+	Description string // What the example does
+}
+
+// Documentation holds the descriptive text used by GenerateManPage
+type Documentation struct {
+	Name        string    // Command name, overrides the name derived from os.Args[0]
+	Summary     string    // One-line summary for the NAME section
+	Description string    // Free text for the DESCRIPTION section, paragraphs separated by blank lines
+	Examples    []Example // Entries for the EXAMPLES section
+	SeeAlso     []string  // Related pages, e.g. "tsvselect(1)"
+}
+
+// Documenter can be implemented by a command configuration to supply
+// documentation beyond what is reflected from its struct tags
+type Documenter interface {
+	Documentation() Documentation
+}
+
+// documentation returns the command documentation, filling in the name; a
+// subcommand is always named after its group, or the alias it was run as
+func (cmd *GSCommand) documentation() Documentation {
+	var doc Documentation
+	if documenter, ok := cmd.config.(Documenter); ok {
+		doc = documenter.Documentation()
+	}
+	if doc.Name == "" || cmd.groupNamed {
+		doc.Name = cmd.commandName
+	}
+	return doc
+}
+
+// argumentPlaceholders returns the names of the values a flag consumes,
+// e.g. ["field", "content"] for -match
+func argumentPlaceholders(meta FieldMeta) []string {
+	switch meta.Type {
+	case FieldTypeFlag:
+		return nil
+	case FieldTypeMulti:
+		names := make([]string, len(meta.Args))
+		for i, arg := range meta.Args {
+			names[i] = arg.Name
+		}
+		return names
+	case FieldTypeString:
+		if len(meta.Enum) > 0 {
+			return []string{strings.Join(meta.Enum, "|")}
+		}
+		return []string{"string"}
+	default:
+		return []string{string(meta.Type)}
+	}
+}
+
+// fieldsByScope splits the command fields into global and local groups,
+// each with required fields first
+func (cmd *GSCommand) fieldsByScope() (global, local []FieldMeta) {
+	for _, field := range cmd.orderedFields() {
+		if field.Scope == ScopeLocal {
+			local = append(local, field)
+		} else {
+			global = append(global, field)
+		}
+	}
+	return global, local
+}
+
+// GenerateManPage generates a man(7) page from the field metadata
+func (cmd *GSCommand) GenerateManPage() string {
+	doc := cmd.documentation()
+	global, local := cmd.fieldsByScope()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, ".TH %s 1 \"\" \"%s\" \"User Commands\"\n",
+		roffEscape(strings.ToUpper(doc.Name)), roffEscape(doc.Name))
+
+	sb.WriteString(".SH NAME\n")
+	if doc.Summary != "" {
+		fmt.Fprintf(&sb, "%s \\- %s\n", roffEscape(doc.Name), roffEscape(doc.Summary))
+	} else {
+		fmt.Fprintf(&sb, "%s\n", roffEscape(doc.Name))
+	}
+
+	sb.WriteString(".SH SYNOPSIS\n")
+	writeRoffSynopsis(&sb, doc.Name, global, local)
+
+	if doc.Description != "" {
+		sb.WriteString(".SH DESCRIPTION\n")
+		writeRoffParagraphs(&sb, doc.Description)
+	}
+
+	sb.WriteString(".SH OPTIONS\n")
+	if len(global) > 0 {
+		sb.WriteString(".SS Global options\n")
+		sb.WriteString("Global options apply to the whole command, wherever they appear.\n")
+		for _, field := range global {
+			writeRoffOption(&sb, field)
+		}
+	}
+	if len(local) > 0 {
+		sb.WriteString(".SS Clause options\n")
+		sb.WriteString("Clause options apply only to the clause in which they appear.\n")
+		for _, field := range local {
+			writeRoffOption(&sb, field)
+		}
+	}
+	sb.WriteString(".SS Built-in options\n")
+	writeRoffSpecialFlags(&sb)
+
+	writeRoffClauses(&sb)
+	writeRoffExamples(&sb, doc)
+
+	return sb.String()
+}
+
+// writeRoffSynopsis writes the synopsis lines of a command with the given
+// global and clause options
+func writeRoffSynopsis(sb *strings.Builder, name string, global, local []FieldMeta) {
+	fmt.Fprintf(sb, ".B %s\n", roffEscape(name))
+	if len(global) > 0 {
+		sb.WriteString("[\\fIglobal-options\\fR]\n")
+	}
+	sb.WriteString("[\\fIfile\\fR]\n")
+	if len(local) > 0 {
+		sb.WriteString("[\\fIclause-options\\fR]\n")
+		sb.WriteString("[\\fB+\\fR|\\fB\\-\\fR \\fIclause-options\\fR ...]\n")
+	}
+}
+
+// writeRoffSpecialFlags writes a tagged paragraph for each built-in option
+func writeRoffSpecialFlags(sb *strings.Builder) {
+	for _, special := range specialFlags {
+		fmt.Fprintf(sb, ".TP\n.B %s\n%s\n", roffEscape(special.Name), roffLine(special.Help))
+	}
+}
+
+// writeRoffClauses writes the CLAUSES section explaining clause separators
+func writeRoffClauses(sb *strings.Builder) {
+	sb.WriteString(".SH CLAUSES\n")
+	sb.WriteString("The command line is divided into clauses by the separators ")
+	sb.WriteString("\\fB\\-\\fR and \\fB+\\fR.\n")
+	sb.WriteString("Switches within a clause are combined with AND, and clauses are combined with OR.\n")
+	sb.WriteString(".TP\n.B \\-\nStarts a new clause.\n")
+	sb.WriteString(".TP\n.B +\nStarts a new negated clause.\n")
+	sb.WriteString(".PP\n")
+	sb.WriteString("Any switch may also be written with a \\fB+\\fR prefix instead of \\fB\\-\\fR, ")
+	sb.WriteString("e.g. \\fB+quiet\\fR, to negate it. ")
+	sb.WriteString("A negated flag is set to false; a negated value switch is marked as negated ")
+	sb.WriteString("and its meaning is inverted by the command.\n")
+}
+
+// writeRoffExamples writes the EXAMPLES and SEE ALSO sections of doc
+func writeRoffExamples(sb *strings.Builder, doc Documentation) {
+	if len(doc.Examples) > 0 {
+		sb.WriteString(".SH EXAMPLES\n")
+		for _, example := range doc.Examples {
+			if example.Description != "" {
+				fmt.Fprintf(sb, ".PP\n%s\n", roffLine(example.Description))
+			}
+			fmt.Fprintf(sb, ".PP\n.RS\n.nf\n%s\n.fi\n.RE\n", roffLine(example.Command))
+		}
+	}
+
+	if len(doc.SeeAlso) > 0 {
+		sb.WriteString(".SH SEE ALSO\n")
+		refs := make([]string, len(doc.SeeAlso))
+		for i, ref := range doc.SeeAlso {
+			refs[i] = roffManRef(ref)
+		}
+		sb.WriteString(strings.Join(refs, ",\n") + "\n")
+	}
+}
+
+// writeRoffOption writes a tagged paragraph describing a single option
+func writeRoffOption(sb *strings.Builder, field FieldMeta) {
+	flag := parseFlagName(field.Name)
+	sb.WriteString(".TP\n")
+	fmt.Fprintf(sb, "\\fB%s\\fR", roffEscape(flag))
+	for _, name := range argumentPlaceholders(field) {
+		fmt.Fprintf(sb, " \\fI%s\\fR", roffEscape(name))
+	}
+	sb.WriteString("\n")
+
+	if field.Help != "" {
+		sb.WriteString(roffLine(field.Help) + "\n")
+	}
+
+	var details []string
+	if field.Type == FieldTypeMulti {
+		args := make([]string, len(field.Args))
+		for i, arg := range field.Args {
+			args[i] = fmt.Sprintf("\\fI%s\\fR (%s)", roffEscape(arg.Name), arg.Type)
+		}
+		details = append(details, "Arguments: "+strings.Join(args, ", ")+".")
+	}
+	if len(field.Enum) > 0 {
+		values := make([]string, len(field.Enum))
+		for i, value := range field.Enum {
+			values[i] = "\\fB" + roffEscape(value) + "\\fR"
+		}
+		details = append(details, "One of: "+strings.Join(values, ", ")+".")
+	}
+	if field.DefaultValue != nil {
+		details = append(details, fmt.Sprintf("Default: \\fB%s\\fR.", roffEscape(fmt.Sprint(field.DefaultValue))))
+	}
+	if field.Suffix != "" {
+		details = append(details, fmt.Sprintf("Files matching: \\fB%s\\fR.", roffEscape(field.Suffix)))
+	}
+	if field.Mode == ModeList {
+		details = append(details, "May be repeated.")
+	}
+	if field.Required {
+		details = append(details, "Required.")
+	}
+	for _, detail := range details {
+		sb.WriteString(".br\n" + detail + "\n")
+	}
+}
+
+// writeRoffParagraphs writes text with blank-line separated paragraphs
+func writeRoffParagraphs(sb *strings.Builder, text string) {
+	for i, paragraph := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		if i > 0 {
+			sb.WriteString(".PP\n")
+		}
+		for _, line := range strings.Split(paragraph, "\n") {
+			sb.WriteString(roffLine(strings.TrimSpace(line)) + "\n")
+		}
+	}
+}
+
+// roffManRef formats "name(1)" as a bold name followed by the section
+func roffManRef(ref string) string {
+	if open := strings.Index(ref, "("); open > 0 && strings.HasSuffix(ref, ")") {
+		return fmt.Sprintf(".BR %s %s", roffEscape(ref[:open]), ref[open:])
+	}
+	return ".B " + roffEscape(ref)
+}
+
+// roffEscape escapes text for inclusion in a roff document
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\e")
+	return strings.ReplaceAll(s, "-", "\\-")
+}
+
+// roffLine escapes text that starts a line, protecting leading control characters
+func roffLine(s string) string {
+	s = roffEscape(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = "\\&" + s
+	}
+	return s
+}
+
+// helpColumnLimit is the widest flag column before descriptions move to their own line
+const helpColumnLimit = 32
+
+// GenerateHelp generates help text from the field metadata, wrapped to the terminal width
+func (cmd *GSCommand) GenerateHelp() string {
+	doc := cmd.documentation()
+	global, local := cmd.fieldsByScope()
+	width := terminalWidth()
+	defaults, _ := cmd.layeredDefaults() // Invalid values are reported when parsing
+
+	var sb strings.Builder
+	usage := "Usage: " + doc.Name
+	if len(global) > 0 {
+		usage += " [options]"
+	}
+	usage += " [file]"
+	if len(local) > 0 {
+		usage += " [clause-options] [+|- clause-options ...]"
+	}
+	sb.WriteString(usage + "\n")
+	if doc.Summary != "" {
+		sb.WriteString("\n" + wrapText(doc.Summary, width, 0) + "\n")
+	}
+
+	column := 0
+	for _, field := range cmd.fields {
+		if n := len(flagSignature(field)); n > column {
+			column = n
+		}
+	}
+	column += 4 // two spaces of indent and two of padding
+	if column > helpColumnLimit {
+		column = helpColumnLimit
+	}
+
+	if len(global) > 0 {
+		sb.WriteString("\nOptions:\n")
+		for _, field := range global {
+			writeHelpOption(&sb, field, defaults, column, width)
+		}
+	}
+	if len(local) > 0 {
+		sb.WriteString("\nClause options (apply to the clause they appear in):\n")
+		for _, field := range local {
+			writeHelpOption(&sb, field, defaults, column, width)
+		}
+	}
+
+	if presets := cmd.presets(); len(presets) > 0 {
+		if cmd.usesPresetFlag() {
+			sb.WriteString("\nPresets (-preset name or @@name):\n")
+		} else {
+			sb.WriteString("\nPresets (@@name):\n")
+		}
+		for _, name := range presetNames(presets) {
+			writeHelpPreset(&sb, name, presets[name], column, width)
+		}
+	}
+
+	sb.WriteString("\n")
+	if ordered := cmd.orderedFields(); len(ordered) > 0 && ordered[0].Required {
+		sb.WriteString("Options marked * are required.\n")
+	}
+	sb.WriteString(wrapText("Clauses are separated by - (new clause) and + (new negated clause). "+
+		"Switches within a clause are ANDed and clauses are ORed.", width, 0))
+	sb.WriteString("\n")
+	if len(global)+len(local) > 0 {
+		sb.WriteString(wrapText(cmd.defaultsHint(), width, 0) + "\n")
+	}
+
+	return sb.String()
+}
+
+// flagSignature returns a flag with its value placeholders, e.g. "-match <field> <content>"
+func flagSignature(field FieldMeta) string {
+	signature := parseFlagName(field.Name)
+	for _, name := range argumentPlaceholders(field) {
+		signature += " <" + name + ">"
+	}
+	if field.Required {
+		signature += " *"
+	}
+	return signature
+}
+
+// helpDetails returns the bracketed annotations shown after a flag's help text;
+// source is where a default other than the tag's came from
+func helpDetails(field FieldMeta, source string) []string {
+	var details []string
+	if field.Required {
+		details = append(details, "required")
+	}
+	switch field.Type {
+	case FieldTypeMulti:
+		// Only spell out argument types that the placeholders don't already show
+		var typed []string
+		for _, arg := range field.Args {
+			if arg.Name != string(arg.Type) {
+				typed = append(typed, fmt.Sprintf("%s: %s", arg.Name, arg.Type))
+			}
+		}
+		details = append(details, typed...)
+	case FieldTypeString:
+		if len(field.Enum) > 0 {
+			details = append(details, "one of: "+strings.Join(field.Enum, ", "))
+		}
+	default:
+		details = append(details, "type: "+string(field.Type))
+	}
+	if field.DefaultValue != nil {
+		if source != "" {
+			details = append(details, fmt.Sprintf("default: %v from %s", field.DefaultValue, source))
+		} else {
+			details = append(details, fmt.Sprintf("default: %v", field.DefaultValue))
+		}
+	}
+	if field.Suffix != "" {
+		details = append(details, "files: *"+field.Suffix)
+	}
+	if field.Mode == ModeList {
+		details = append(details, "repeatable")
+	}
+
+	negated := "+" + parseFlagName(field.Name)[1:]
+	switch {
+	case field.Type == FieldTypeFlag:
+		details = append(details, negated+" to disable")
+	case field.Scope == ScopeLocal:
+		details = append(details, negated+" to negate")
+	}
+	return details
+}
+
+// writeHelpOption writes one option entry, wrapping its description to width.
+// The default shown is the effective one from defaults, with its source.
+func writeHelpOption(sb *strings.Builder, field FieldMeta, defaults map[string]layeredDefault, column, width int) {
+	signature := "  " + flagSignature(field)
+
+	def := defaults[field.Name]
+	field.DefaultValue = def.value
+	text := field.Help
+	if details := helpDetails(field, def.source); len(details) > 0 {
+		if text != "" {
+			text += " "
+		}
+		text += "[" + strings.Join(details, "; ") + "]"
+	}
+
+	if len(signature)+2 > column {
+		// Signature too long for the column, start the description on the next line
+		sb.WriteString(signature + "\n")
+		sb.WriteString(strings.Repeat(" ", column))
+	} else {
+		sb.WriteString(signature + strings.Repeat(" ", column-len(signature)))
+	}
+	sb.WriteString(wrapText(text, width, column) + "\n")
+}
+
+// writeHelpPreset writes a preset with its expansion, wrapped like an option
+func writeHelpPreset(sb *strings.Builder, name string, p preset, column, width int) {
+	signature := "  " + name
+	if len(signature)+2 > column {
+		sb.WriteString(signature + "\n" + strings.Repeat(" ", column))
+	} else {
+		sb.WriteString(signature + strings.Repeat(" ", column-len(signature)))
+	}
+	sb.WriteString(wrapText(p.String()+" [from "+p.source+"]", width, column) + "\n")
+}
+
+// wrapText wraps text at word boundaries; continuation lines are indented by indent
+// and the first line is assumed to already start at that column
+func wrapText(text string, width, indent int) string {
+	available := width - indent
+	if available < 20 {
+		available = 20
+	}
+
+	var sb strings.Builder
+	lineLen := 0
+	for _, word := range strings.Fields(text) {
+		switch {
+		case lineLen == 0:
+		case lineLen+1+len(word) > available:
+			sb.WriteString("\n" + strings.Repeat(" ", indent))
+			lineLen = 0
+		default:
+			sb.WriteString(" ")
+			lineLen++
+		}
+		sb.WriteString(word)
+		lineLen += len(word)
+	}
+	return sb.String()
+}
+
+// terminalWidth returns the width to wrap help text to: $COLUMNS if set, else
+// the width of the terminal on stdout, else 80
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	return 80
+}
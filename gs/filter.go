@@ -0,0 +1,169 @@
+package gs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Condition tests the value of a row's field
+type Condition func(value string) bool
+
+// Predicate compiles the arguments of a predicate switch that follow its
+// field name, e.g. the pattern of "-match host ^web", into a Condition
+type Predicate func(args []string) (Condition, error)
+
+// Filter selects rows using the predicate switches of parsed clauses.
+// Switches within a clause are ANDed, clauses are ORed, and both +switch and
+// a + clause negate. Clauses with no predicate switches take no part in
+// selecting rows, as their fields (globals, defaults and switches such as -y)
+// say nothing about which rows to keep; with no predicates at all every row is
+// kept. MatchClause matches every row for such a clause, negated or not, so
+// that "-y a + -y b" plots every row of both series.
+type Filter struct {
+	clauses []filterClause
+}
+
+// filterClause is the compiled form of one ClauseSet
+type filterClause struct {
+	negated bool
+	empty   bool // The clause has no predicate switches
+	terms   []filterTerm
+}
+
+// filterTerm is one occurrence of a predicate switch
+type filterTerm struct {
+	index   int // Column of the field in the header
+	negated bool
+	test    Condition
+}
+
+// NewFilter compiles the predicate switches of clauses against header.
+// predicates maps config field names, e.g. "Match", to the Predicate for that
+// switch; other fields are ignored. Each predicate switch must be a multi
+// switch whose first argument is the field name. Unknown fields and invalid
+// arguments are reported together as ValidationErrors.
+func NewFilter(clauses []ClauseSet, predicates map[string]Predicate, header []string) (*Filter, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, exists := columns[name]; !exists {
+			columns[name] = i
+		}
+	}
+
+	// Visit switches in a fixed order so that errors are reported consistently
+	names := make([]string, 0, len(predicates))
+	for name := range predicates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filter := &Filter{}
+	var errs []error
+	for i, clause := range clauses {
+		compiled := filterClause{negated: clause.IsNegated}
+		for _, name := range names {
+			value, ok := clause.Fields[name]
+			if !ok {
+				continue
+			}
+			meta := clause.fieldMeta(name)
+			flag := parseFlagName(name)
+			for _, item := range toList(value) {
+				term, err := compileTerm(item, meta, predicates[name], columns, header)
+				if err != nil {
+					errs = append(errs, ValidationError{Field: flag, Clause: i + 1, Message: err.Error()})
+					continue
+				}
+				compiled.terms = append(compiled.terms, term)
+			}
+		}
+		compiled.empty = len(compiled.terms) == 0
+		filter.clauses = append(filter.clauses, compiled)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return filter, nil
+}
+
+// compileTerm compiles one parsed occurrence of a predicate switch
+func compileTerm(item interface{}, meta *FieldMeta, predicate Predicate, columns map[string]int, header []string) (filterTerm, error) {
+	args, ok := item.(map[string]interface{})
+	if !ok || meta == nil || len(meta.Args) == 0 {
+		return filterTerm{}, fmt.Errorf("predicate switches must take a field and arguments")
+	}
+
+	values := make([]string, len(meta.Args))
+	for i, spec := range meta.Args {
+		values[i] = toString(args[spec.Name])
+	}
+
+	index, ok := columns[values[0]]
+	if !ok {
+		return filterTerm{}, UnknownFieldError(values[0], "", header)
+	}
+	test, err := predicate(values[1:])
+	if err != nil {
+		return filterTerm{}, err
+	}
+	return filterTerm{index: index, negated: isNegated(args), test: test}, nil
+}
+
+// Match reports whether row satisfies any clause
+func (f *Filter) Match(row []string) bool {
+	considered := false
+	for i, clause := range f.clauses {
+		if clause.empty {
+			continue
+		}
+		considered = true
+		if f.MatchClause(i, row) {
+			return true
+		}
+	}
+	return !considered
+}
+
+// MatchClause reports whether row satisfies clause i alone, for commanders
+// such as tsv2chart that treat each clause as a separate series
+func (f *Filter) MatchClause(i int, row []string) bool {
+	clause := f.clauses[i]
+	if clause.empty {
+		return true
+	}
+	matched := true
+	for _, term := range clause.terms {
+		// Cells missing from short rows are empty
+		value := ""
+		if term.index < len(row) {
+			value = row[term.index]
+		}
+		if term.test(value) == term.negated {
+			matched = false
+			break
+		}
+	}
+	return matched != clause.negated
+}
+
+// Rows returns the rows that satisfy any clause
+func (f *Filter) Rows(rows [][]string) [][]string {
+	return f.selectRows(rows, f.Match)
+}
+
+// ClauseRows returns the rows that satisfy clause i
+func (f *Filter) ClauseRows(i int, rows [][]string) [][]string {
+	return f.selectRows(rows, func(row []string) bool { return f.MatchClause(i, row) })
+}
+
+func (f *Filter) selectRows(rows [][]string, match func([]string) bool) [][]string {
+	selected := [][]string{}
+	for _, row := range rows {
+		if match(row) {
+			selected = append(selected, row)
+		}
+	}
+	return selected
+}
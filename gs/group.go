@@ -0,0 +1,410 @@
+package gs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Group is a multi-tool binary that dispatches on its first argument to one
+// of several commands, e.g. "tsv select" and "tsv chart". Each subcommand is
+// a GSCommand with its own config struct and clause parsing; the group adds
+// combined help, man page and shell completion, which also completes the
+// subcommand names. Like busybox, the binary can also be installed under
+// aliases, e.g. a tsv2chart symlink that runs "tsv chart" (see Run and
+// Install).
+type Group struct {
+	name     string
+	doc      Documentation
+	commands []*GSCommand
+	names    []string // Subcommand name of each command
+	aliases  []groupAlias
+}
+
+// groupAlias is a binary name that runs a subcommand directly
+type groupAlias struct {
+	name string
+	cmd  *GSCommand
+}
+
+// NewGroup creates an empty Group. doc describes the group as a whole; its
+// Name defaults to the name of the binary.
+func NewGroup(doc Documentation) *Group {
+	if doc.Name == "" {
+		doc.Name = "command" // fallback
+		if len(os.Args) > 0 {
+			doc.Name = filepath.Base(os.Args[0])
+		}
+	}
+	return &Group{name: doc.Name, doc: doc}
+}
+
+// Add registers config, which must implement Commander, as the subcommand
+// name, and as the binary names aliases, e.g. "tsv2chart" for "chart". The
+// returned GSCommand can be configured like any other, e.g. with
+// SetValidateFields; its help and errors name it "<group> <name>".
+func (g *Group) Add(name string, config interface{}, aliases ...string) (*GSCommand, error) {
+	if name == "" || strings.HasPrefix(name, "-") || strings.HasPrefix(name, "+") || strings.ContainsAny(name, " \t\n") {
+		return nil, fmt.Errorf("invalid subcommand name: %q", name)
+	}
+	if g.Lookup(name) != nil {
+		return nil, fmt.Errorf("duplicate subcommand: %s", name)
+	}
+	for _, alias := range aliases {
+		if alias == "" || strings.ContainsAny(alias, "/ \t\n") || alias != filepath.Base(alias) {
+			return nil, fmt.Errorf("invalid alias for %s: %q", name, alias)
+		}
+		if alias == g.name || g.lookupAlias(alias) != nil {
+			return nil, fmt.Errorf("duplicate alias: %s", alias)
+		}
+	}
+	if _, ok := config.(Commander); !ok {
+		return nil, fmt.Errorf("subcommand %s does not implement Commander interface", name)
+	}
+
+	cmd, err := NewCommand(config)
+	if err != nil {
+		return nil, fmt.Errorf("subcommand %s: %w", name, err)
+	}
+	cmd.commandName = g.name + " " + name
+	cmd.group = g
+	cmd.groupNamed = true
+	cmd.configID = g.name + "-" + name
+
+	g.commands = append(g.commands, cmd)
+	g.names = append(g.names, name)
+	for _, alias := range aliases {
+		g.aliases = append(g.aliases, groupAlias{name: alias, cmd: cmd})
+	}
+	return cmd, nil
+}
+
+// Lookup returns the subcommand registered as name, or nil
+func (g *Group) Lookup(name string) *GSCommand {
+	for i, registered := range g.names {
+		if registered == name {
+			return g.commands[i]
+		}
+	}
+	return nil
+}
+
+// lookupAlias returns the subcommand run by the binary name alias, or nil
+func (g *Group) lookupAlias(alias string) *GSCommand {
+	for _, registered := range g.aliases {
+		if registered.name == alias {
+			return registered.cmd
+		}
+	}
+	return nil
+}
+
+// aliasesOf returns the aliases of cmd
+func (g *Group) aliasesOf(cmd *GSCommand) []string {
+	var aliases []string
+	for _, alias := range g.aliases {
+		if alias.cmd == cmd {
+			aliases = append(aliases, alias.name)
+		}
+	}
+	return aliases
+}
+
+// Run dispatches on the name the binary was invoked as, argv[0]. Invoked as
+// an alias it runs that subcommand with the remaining arguments as a command
+// in its own right, named after the alias; otherwise it runs Execute. Pass
+// os.Args. Either way the subcommand reads the same config files, environment
+// variables and piped input sidecar, named after "<group>-<name>".
+func (g *Group) Run(ctx context.Context, argv []string) error {
+	if len(argv) == 0 {
+		return g.Execute(ctx, nil)
+	}
+	invoked := filepath.Base(argv[0])
+	if cmd := g.lookupAlias(invoked); cmd != nil {
+		cmd.commandName = invoked
+		cmd.group = nil // Help, errors and completion scripts name the alias alone
+		return cmd.Execute(ctx, argv[1:])
+	}
+	return g.Execute(ctx, argv[1:])
+}
+
+// scriptNames returns the names completion scripts are registered for: the
+// group and each alias
+func (g *Group) scriptNames() []string {
+	names := []string{g.name}
+	for _, alias := range g.aliases {
+		names = append(names, alias.name)
+	}
+	return names
+}
+
+// completionScript concatenates the script generate produces for each name
+func (g *Group) completionScript(generate func(string) string) string {
+	var sb strings.Builder
+	for i, name := range g.scriptNames() {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(generate(name))
+	}
+	return sb.String()
+}
+
+// Execute runs the subcommand named by args[0] with the remaining arguments,
+// or handles the group's own -help, -man and completion flags
+func (g *Group) Execute(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "-help", "--help":
+			fmt.Println(g.GenerateHelp())
+			return nil
+		case "-man":
+			fmt.Println(g.GenerateManPage())
+			return nil
+		case "-complete", "-complete-desc", "-complete-v1", "-complete-bash":
+			return writeCompletions(os.Stdout, args, g.completeDescribed)
+		case "-bash-completion":
+			fmt.Print(g.completionScript(bashCompletionScript))
+			return nil
+		case "-zsh-completion":
+			fmt.Print(g.completionScript(zshCompletionScript))
+			return nil
+		case "-fish-completion":
+			fmt.Print(g.completionScript(fishCompletionScript))
+			return nil
+		}
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("no command given; expected one of: %s", strings.Join(g.names, ", "))
+	}
+	cmd := g.Lookup(args[0])
+	if cmd == nil {
+		msg := fmt.Sprintf("unknown command '%s'", args[0])
+		if suggestion := Suggest(args[0], g.names); suggestion != "" {
+			msg += fmt.Sprintf("; did you mean '%s'?", suggestion)
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	return cmd.Execute(ctx, args[1:])
+}
+
+// completeDescribed completes subcommand names for the first argument, and
+// otherwise hands the remaining arguments to the subcommand
+func (g *Group) completeDescribed(args []string, pos int) ([]Candidate, error) {
+	if pos > 0 {
+		if len(args) == 0 {
+			return nil, nil
+		}
+		cmd := g.Lookup(args[0])
+		if cmd == nil {
+			return nil, nil
+		}
+		return cmd.completeDescribed(args[1:], pos-1)
+	}
+
+	current := ""
+	if len(args) > 0 {
+		current = args[0]
+	}
+
+	var candidates []Candidate
+	if strings.HasPrefix(current, "-") {
+		for _, special := range specialFlags {
+			if strings.HasPrefix(special.Name, current) {
+				candidates = append(candidates, Candidate{Value: special.Name, Kind: "flag", Description: special.Help})
+			}
+		}
+		return candidates, nil
+	}
+	for i, name := range g.names {
+		if strings.HasPrefix(name, current) {
+			summary := g.commands[i].documentation().Summary
+			candidates = append(candidates, Candidate{Value: name, Kind: "command", Description: summary})
+		}
+	}
+	return candidates, nil
+}
+
+// GenerateHelp generates help text listing the subcommands
+func (g *Group) GenerateHelp() string {
+	width := terminalWidth()
+
+	var sb strings.Builder
+	sb.WriteString("Usage: " + g.name + " <command> [arguments]\n")
+	if g.doc.Summary != "" {
+		sb.WriteString("\n" + wrapText(g.doc.Summary, width, 0) + "\n")
+	}
+
+	column := 0
+	for _, name := range g.names {
+		column = max(column, len(name))
+	}
+	column = min(column+4, helpColumnLimit) // two spaces of indent and two of padding
+
+	sb.WriteString("\nCommands:\n")
+	for i, name := range g.names {
+		entry := "  " + name
+		summary := g.commands[i].documentation().Summary
+		if aliases := g.aliasesOf(g.commands[i]); len(aliases) > 0 {
+			summary += " (also " + strings.Join(aliases, ", ") + ")"
+		}
+		if len(entry)+2 > column {
+			sb.WriteString(entry + "\n" + strings.Repeat(" ", column))
+		} else {
+			sb.WriteString(entry + strings.Repeat(" ", column-len(entry)))
+		}
+		sb.WriteString(wrapText(summary, width, column) + "\n")
+	}
+
+	sb.WriteString("\n" + wrapText(fmt.Sprintf("Run '%s <command> -help' for the options of a command.", g.name), width, 0) + "\n")
+	return sb.String()
+}
+
+// GenerateManPage generates a man(7) page for the group, describing each
+// subcommand and its options
+func (g *Group) GenerateManPage() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, ".TH %s 1 \"\" \"%s\" \"User Commands\"\n",
+		roffEscape(strings.ToUpper(g.name)), roffEscape(g.name))
+
+	sb.WriteString(".SH NAME\n")
+	if g.doc.Summary != "" {
+		fmt.Fprintf(&sb, "%s \\- %s\n", roffEscape(g.name), roffEscape(g.doc.Summary))
+	} else {
+		fmt.Fprintf(&sb, "%s\n", roffEscape(g.name))
+	}
+
+	sb.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(&sb, ".B %s\n\\fIcommand\\fR [\\fIarguments\\fR]\n", roffEscape(g.name))
+
+	if g.doc.Description != "" {
+		sb.WriteString(".SH DESCRIPTION\n")
+		writeRoffParagraphs(&sb, g.doc.Description)
+	}
+
+	sb.WriteString(".SH COMMANDS\n")
+	for i, name := range g.names {
+		cmd := g.commands[i]
+		doc := cmd.documentation()
+		global, local := cmd.fieldsByScope()
+
+		fmt.Fprintf(&sb, ".SS %s\n", roffEscape(name))
+		writeRoffSynopsis(&sb, cmd.commandName, global, local)
+		if doc.Summary != "" {
+			sb.WriteString(".PP\n" + roffLine(doc.Summary) + "\n")
+		}
+		if aliases := g.aliasesOf(cmd); len(aliases) > 0 {
+			refs := make([]string, len(aliases))
+			for i, alias := range aliases {
+				refs[i] = "\\fB" + roffEscape(alias) + "\\fR"
+			}
+			sb.WriteString(".PP\nAlso installed as " + strings.Join(refs, ", ") + ".\n")
+		}
+		if doc.Description != "" {
+			sb.WriteString(".PP\n")
+			writeRoffParagraphs(&sb, doc.Description)
+		}
+		if len(global) > 0 {
+			sb.WriteString(".PP\nGlobal options:\n")
+			for _, field := range global {
+				writeRoffOption(&sb, field)
+			}
+		}
+		if len(local) > 0 {
+			sb.WriteString(".PP\nClause options:\n")
+			for _, field := range local {
+				writeRoffOption(&sb, field)
+			}
+		}
+	}
+
+	sb.WriteString(".SH OPTIONS\n")
+	sb.WriteString("These built-in options are accepted by the group and by each command.\n")
+	writeRoffSpecialFlags(&sb)
+
+	writeRoffClauses(&sb)
+	writeRoffExamples(&sb, g.doc)
+
+	return sb.String()
+}
+
+// InstallOptions are the directories Install writes to; an empty directory
+// is skipped
+type InstallOptions struct {
+	Binary  string // Binary the aliases link to; defaults to os.Executable
+	BinDir  string // Directory for a symlink named after each alias
+	BashDir string // Directory for bash completion files, e.g. ~/.local/share/bash-completion/completions
+	ZshDir  string // Directory on $fpath for zsh completion functions
+	FishDir string // Directory for fish completions, e.g. ~/.config/fish/completions
+}
+
+// Install creates a symlink to the binary for each alias in opts.BinDir, and
+// writes completion files for the group and every alias, one per name as
+// bash-completion, zsh and fish load them on demand. Existing symlinks are
+// replaced, but other files are never overwritten by a symlink.
+func (g *Group) Install(opts InstallOptions) error {
+	if opts.BinDir != "" && len(g.aliases) > 0 {
+		binary := opts.Binary
+		if binary == "" {
+			executable, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("finding binary: %w", err)
+			}
+			binary = executable
+		}
+		binary, err := filepath.Abs(binary)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(opts.BinDir, 0o755); err != nil {
+			return err
+		}
+		for _, alias := range g.aliases {
+			if err := replaceSymlink(binary, filepath.Join(opts.BinDir, alias.name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, shell := range []struct {
+		dir      string
+		file     func(name string) string
+		generate func(name string) string
+	}{
+		{opts.BashDir, func(name string) string { return name }, bashCompletionScript},
+		{opts.ZshDir, func(name string) string { return "_" + name }, zshCompletionScript},
+		{opts.FishDir, func(name string) string { return name + ".fish" }, fishCompletionScript},
+	} {
+		if shell.dir == "" {
+			continue
+		}
+		for _, name := range g.scriptNames() {
+			path := filepath.Join(shell.dir, shell.file(name))
+			if err := writeFileAtomic(path, []byte(shell.generate(name))); err != nil {
+				return fmt.Errorf("writing %s: %w", path, err)
+			}
+			// Shells may load completions as another user, e.g. from a system directory
+			if err := os.Chmod(path, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replaceSymlink creates link pointing at target, replacing an existing
+// symlink but not any other file
+func replaceSymlink(target, link string) error {
+	if info, err := os.Lstat(link); err == nil {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("%s exists and is not a symlink", link)
+		}
+		if err := os.Remove(link); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(target, link)
+}
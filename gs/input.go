@@ -0,0 +1,238 @@
+package gs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rosscartlidge/gogstools/gs/tsv"
+)
+
+// commandKey is the context key under which Execute stores the running command
+type commandKey struct{}
+
+// withCommand returns a context carrying cmd for OpenInput
+func withCommand(ctx context.Context, cmd *GSCommand) context.Context {
+	return context.WithValue(ctx, commandKey{}, cmd)
+}
+
+// headerEnv names the environment variable giving the fields of piped input
+// to completion, e.g. GS_HEADER="time,cpu_usage" (tab or comma separated)
+const headerEnv = "GS_HEADER"
+
+// recordEnv names the environment variable that opts in to recording the
+// start of piped input for completion, e.g. GS_RECORD_STDIN=1
+const recordEnv = "GS_RECORD_STDIN"
+
+// sidecarMaxAge is how long recorded input is offered to completion, so that
+// fields of an unrelated earlier pipeline are not presented as the current one
+const sidecarMaxAge = 30 * time.Minute
+
+// OpenInput opens an input file for a Commander; "" and "-" mean standard input.
+// Input compressed with gzip or bzip2 (or zstd, see tsv.Open) is decompressed.
+// Anything the command has already read from standard input, such as the
+// header read to validate field names, is replayed by the returned reader.
+// If GS_RECORD_STDIN is set, closing it records the header and first rows
+// read in a sidecar, so that completion works in later pipelines.
+func OpenInput(ctx context.Context, filename string) (io.ReadCloser, error) {
+	if filename == "" || filename == "-" {
+		if cmd, ok := ctx.Value(commandKey{}).(*GSCommand); ok {
+			// Peek so that compressed input is detected; errors, such as empty
+			// input, are left to the commander's own reading
+			cmd.PeekStdin(0)
+			if !recordingStdin() {
+				return io.NopCloser(cmd.stdin), nil
+			}
+			return &sidecarRecorder{cmd: cmd, input: cmd.stdin}, nil
+		}
+		if isTerminal(os.Stdin) {
+			return io.NopCloser(os.Stdin), nil
+		}
+		return tsv.Decompress(os.Stdin)
+	}
+
+	return tsv.Open(filename)
+}
+
+// errorReader fails every read, standing in for unreadable input
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// stdinPeek holds what has been read ahead from standard input
+type stdinPeek struct {
+	source   io.Reader    // Standard input, decompressed
+	consumed bytes.Buffer // Bytes read from source, replayed by GSCommand.stdin
+	reader   *tsv.Reader  // Reads source, copying into consumed
+	header   tsv.Header
+	rows     []tsv.Record
+	err      error // Set once the input has ended or failed
+}
+
+// PeekStdin reads the header and up to rows records of standard input
+// without consuming them: input opened with OpenInput replays everything
+// read. It must be called before the commander reads standard input, and
+// does not read a terminal at all.
+func (cmd *GSCommand) PeekStdin(rows int) (tsv.Header, []tsv.Record, error) {
+	peek := cmd.stdinPeek
+	if peek == nil {
+		if isTerminal(cmd.stdin) {
+			return nil, nil, fmt.Errorf("standard input is a terminal")
+		}
+		source, err := tsv.Decompress(cmd.stdin)
+		if err != nil {
+			err = fmt.Errorf("reading stdin: %w", err)
+			cmd.stdin = errorReader{err}
+			cmd.stdinPeek = &stdinPeek{err: err}
+			return nil, nil, err
+		}
+		peek = &stdinPeek{source: source}
+		peek.reader = tsv.NewReader(io.TeeReader(peek.source, &peek.consumed))
+		cmd.stdin = io.MultiReader(&peek.consumed, peek.source)
+		cmd.stdinPeek = peek
+
+		peek.header, peek.err = peek.reader.Header()
+		if peek.err != nil {
+			peek.err = fmt.Errorf("reading header from stdin: %w", peek.err)
+			return nil, nil, peek.err
+		}
+	}
+	if peek.header == nil {
+		return nil, nil, peek.err
+	}
+
+	for len(peek.rows) < rows && peek.err == nil {
+		record, err := peek.reader.Read()
+		if err != nil {
+			peek.err = err
+			break
+		}
+		peek.rows = append(peek.rows, record)
+	}
+
+	if len(peek.rows) > rows {
+		return peek.header, peek.rows[:rows], nil
+	}
+	return peek.header, peek.rows, nil
+}
+
+// isTerminal reports whether r is an interactive terminal, which must not be
+// read ahead of the commander
+func isTerminal(r io.Reader) bool {
+	file, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// recordingStdin reports whether the user opted in to recording piped input
+func recordingStdin() bool {
+	record, _ := strconv.ParseBool(os.Getenv(recordEnv))
+	return record
+}
+
+// headerSidecar returns the file recording the header and first rows of the
+// last piped input this command read, or "" if there is no cache directory
+func (cmd *GSCommand) headerSidecar() string {
+	if cmd.cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(cmd.cacheDir, "stdin", cmd.configName()+".tsv")
+}
+
+// sidecarLimit bounds how much of standard input is kept for the sidecar
+const sidecarLimit = 64 * 1024
+
+// sidecarRecorder reads standard input for a commander, keeping the start of
+// it to write the header sidecar when closed
+type sidecarRecorder struct {
+	cmd       *GSCommand
+	input     io.Reader
+	start     bytes.Buffer
+	truncated bool // More was read than start holds
+}
+
+func (r *sidecarRecorder) Read(p []byte) (int, error) {
+	n, err := r.input.Read(p)
+	kept := min(n, sidecarLimit-r.start.Len())
+	r.start.Write(p[:kept])
+	if kept < n {
+		r.truncated = true
+	}
+	return n, err
+}
+
+// Close writes the sidecar from the header and first rows read. It is best
+// effort: completion simply has less to offer without it.
+func (r *sidecarRecorder) Close() error {
+	path := r.cmd.headerSidecar()
+	if path == "" {
+		return nil
+	}
+
+	reader := tsv.NewReader(&r.start)
+	header, err := reader.Header()
+	if err != nil {
+		return nil
+	}
+	var rows []tsv.Record
+	for len(rows) < r.cmd.sampleDepth(nil) {
+		record, err := reader.Read()
+		if err != nil {
+			break
+		}
+		rows = append(rows, record)
+	}
+	if r.truncated && len(rows) > 0 {
+		rows = rows[:len(rows)-1] // The last row may have been cut short
+	}
+
+	writeSidecar(path, header, rows)
+	return nil
+}
+
+// writeSidecar writes a header and rows to path, replacing it atomically
+func writeSidecar(path string, header tsv.Header, rows []tsv.Record) {
+	var buf bytes.Buffer
+	writer := tsv.NewWriter(&buf)
+	writer.WriteHeader(header)
+	for _, row := range rows {
+		writer.Write(row)
+	}
+	if writer.Flush() == nil {
+		writeFileAtomic(path, buf.Bytes())
+	}
+}
+
+// pipedInput returns the input to complete against when no file is named:
+// "" with the header from GS_HEADER if it is set, otherwise the sidecar of
+// the last piped input if recording is on and it was written recently.
+// Expired sidecars are removed.
+func (cmd *GSCommand) pipedInput() (string, tsv.Header) {
+	if hint := os.Getenv(headerEnv); hint != "" {
+		if header, err := tsv.ReadHeader(strings.NewReader(hint)); err == nil {
+			return "", header
+		}
+	}
+	if path := cmd.headerSidecar(); path != "" && recordingStdin() {
+		if info, err := os.Stat(path); err == nil {
+			if time.Since(info.ModTime()) <= sidecarMaxAge {
+				return path, nil
+			}
+			os.Remove(path)
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,103 @@
+package gs
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/rosscartlidge/gogstools/gs/tsv"
+)
+
+// fieldKinds are the values of the kind= tag option, which restricts the
+// columns a field switch accepts: a tsv.Kind name, or numeric for int and
+// float columns
+var fieldKinds = []string{"numeric", "int", "float", "bool", "timestamp", "enum", "text"}
+
+// kindMatches reports whether a column of kind satisfies the kind= option
+// want. Integers are also floats, and enums are also text.
+func kindMatches(want string, kind tsv.Kind) bool {
+	switch want {
+	case "numeric":
+		return kind.Numeric()
+	case "float":
+		return kind.Numeric()
+	case "text":
+		return kind == tsv.Text || kind == tsv.Enum
+	}
+	return want == kind.String()
+}
+
+// ColumnKinds infers the kind of each column of filename, or of standard
+// input if filename is "" or "-", from its opening rows; as many rows are read
+// as values are sampled for completion. Commanders can use it to check or
+// convert columns before use. Results for files are cached like completion
+// data.
+func (cmd *GSCommand) ColumnKinds(filename string) (map[string]tsv.Kind, error) {
+	depth := cmd.sampleDepth(nil)
+	if filename == "" || filename == "-" {
+		header, records, err := cmd.PeekStdin(depth)
+		if err != nil {
+			return nil, err
+		}
+		return kindMap(header, tsv.InferKinds(header, records)), nil
+	}
+
+	kinds, exists := lookupCache(cmd, cmd.kindCache, filename, filename, false, func(entry *cacheEntry) (map[string]tsv.Kind, bool) {
+		return entry.Kinds, entry.Kinds != nil
+	})
+	if exists {
+		return kinds, nil
+	}
+
+	file, err := tsv.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := tsv.NewReader(file)
+	header, err := reader.Header()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
+	}
+	var records []tsv.Record
+	for len(records) < depth {
+		record, err := reader.Read()
+		if err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+
+	kinds = kindMap(header, tsv.InferKinds(header, records))
+	cmd.kindCache[filename] = kinds
+	cmd.updateCache(filename, func(entry *cacheEntry) {
+		entry.Kinds = kinds
+	})
+	return kinds, nil
+}
+
+// kindMap maps the field names of header to their kinds; a name repeated in
+// the header has the kind of its first column
+func kindMap(header tsv.Header, kinds []tsv.Kind) map[string]tsv.Kind {
+	result := make(map[string]tsv.Kind, len(header))
+	for i := len(header) - 1; i >= 0; i-- {
+		result[header[i]] = kinds[i]
+	}
+	return result
+}
+
+// fieldsOfKind filters field name candidates to the columns of filename that
+// satisfy the kind= option of meta. Fields whose kind is unknown are kept.
+func (cmd *GSCommand) fieldsOfKind(filename string, meta *FieldMeta, fields []string) []string {
+	if meta == nil || meta.Kind == "" || filename == "" {
+		return fields
+	}
+	kinds, err := cmd.ColumnKinds(filename)
+	if err != nil {
+		return fields
+	}
+	return slices.DeleteFunc(fields, func(field string) bool {
+		kind, known := kinds[field]
+		return known && !kindMatches(meta.Kind, kind)
+	})
+}
@@ -2,6 +2,7 @@ package gs
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -140,6 +141,17 @@ func parseKeyValue(kv string, meta *FieldMeta) error {
 		meta.Suffix = value
 	case "enum":
 		meta.Enum = parseEnumValues(value)
+	case "sample":
+		sample, err := strconv.Atoi(value)
+		if err != nil || sample <= 0 {
+			return fmt.Errorf("invalid sample size: %s", value)
+		}
+		meta.Sample = sample
+	case "kind":
+		if !slices.Contains(fieldKinds, value) {
+			return fmt.Errorf("invalid kind: %s (expected one of %s)", value, strings.Join(fieldKinds, ", "))
+		}
+		meta.Kind = value
 	default:
 		return fmt.Errorf("unknown key in tag: %s", key)
 	}
@@ -189,39 +201,35 @@ func parseFlagName(fieldName string) string {
 }
 
 // parseArgumentSpecs parses the args specification for multi-argument switches
-// Format: "field:content" or "field,pattern,replacement"
+// Format: "field:content" or "field,pattern,replacement". An argument may be
+// named as name=type, e.g. "field:low=number:high=number", and names must be
+// unique since they key the parsed values.
 func parseArgumentSpecs(value string) ([]ArgumentSpec, error) {
-	// Handle colon-separated format: "field:content"
+	separator := ","
 	if strings.Contains(value, ":") {
-		parts := strings.Split(value, ":")
-		specs := make([]ArgumentSpec, len(parts))
-		
-		for i, part := range parts {
-			part = strings.TrimSpace(part)
-			argType, err := parseArgumentType(part)
-			if err != nil {
-				return nil, err
-			}
-			specs[i] = ArgumentSpec{
-				Name: part,
-				Type: argType,
-			}
-		}
-		return specs, nil
+		separator = ":"
 	}
 	
-	// Handle comma-separated format: "field,pattern,replacement"
-	parts := strings.Split(value, ",")
+	parts := strings.Split(value, separator)
 	specs := make([]ArgumentSpec, len(parts))
+	seen := make(map[string]bool)
 	
 	for i, part := range parts {
-		part = strings.TrimSpace(part)
-		argType, err := parseArgumentType(part)
+		name, typeName := strings.TrimSpace(part), strings.TrimSpace(part)
+		if before, after, found := strings.Cut(part, "="); found {
+			name, typeName = strings.TrimSpace(before), strings.TrimSpace(after)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate argument name %s (name repeated arguments as name=type)", name)
+		}
+		seen[name] = true
+		
+		argType, err := parseArgumentType(typeName)
 		if err != nil {
 			return nil, err
 		}
 		specs[i] = ArgumentSpec{
-			Name: part,
+			Name: name,
 			Type: argType,
 		}
 	}
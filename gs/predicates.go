@@ -0,0 +1,155 @@
+package gs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rosscartlidge/gogstools/gs/tsv"
+)
+
+// Predicates declares the standard predicate switches. Embed it in a config
+// struct and pass StandardPredicates to NewFilter:
+//
+//	type Config struct {
+//		gs.Predicates
+//		Y []string `gs:"field,local,list"`
+//	}
+//
+// Numeric comparisons are false for cells that are not numbers, so +eq rather
+// than -ne also selects non-numeric cells.
+type Predicates struct {
+	Eq    []Comparison `gs:"multi,local,list,args=field:number,kind=numeric,help=Keep rows where field equals number"`
+	Ne    []Comparison `gs:"multi,local,list,args=field:number,kind=numeric,help=Keep rows where field is a number other than number"`
+	Lt    []Comparison `gs:"multi,local,list,args=field:number,kind=numeric,help=Keep rows where field is less than number"`
+	Le    []Comparison `gs:"multi,local,list,args=field:number,kind=numeric,help=Keep rows where field is at most number"`
+	Gt    []Comparison `gs:"multi,local,list,args=field:number,kind=numeric,help=Keep rows where field is greater than number"`
+	Ge    []Comparison `gs:"multi,local,list,args=field:number,kind=numeric,help=Keep rows where field is at least number"`
+	In    []Membership `gs:"multi,local,list,args=field:values=content,help=Keep rows where field is one of the comma-separated values"`
+	Range []Interval   `gs:"multi,local,list,args=field:low=number:high=number,kind=numeric,help=Keep rows where field is between low and high inclusive"`
+	Null  []FieldArg   `gs:"multi,local,list,args=field,help=Keep rows where field is empty or NA/N/A/NULL"`
+}
+
+// Comparison is one -eq, -ne, -lt, -le, -gt or -ge switch
+type Comparison struct {
+	Field   string
+	Value   float64
+	Negated bool
+}
+
+// Membership is one -in switch; Values is comma-separated
+type Membership struct {
+	Field   string
+	Values  string
+	Negated bool
+}
+
+// Interval is one -range switch
+type Interval struct {
+	Field     string
+	Low, High float64
+	Negated   bool
+}
+
+// FieldArg is a switch, such as -null, whose only argument is a field
+type FieldArg struct {
+	Field   string
+	Negated bool
+}
+
+// StandardPredicates maps the fields of Predicates, and the conventional
+// -match field regexp switch, to their Predicates for NewFilter
+var StandardPredicates = map[string]Predicate{
+	"Match": MatchPredicate,
+	"Eq":    comparePredicate(func(a, b float64) bool { return a == b }),
+	"Ne":    comparePredicate(func(a, b float64) bool { return a != b }),
+	"Lt":    comparePredicate(func(a, b float64) bool { return a < b }),
+	"Le":    comparePredicate(func(a, b float64) bool { return a <= b }),
+	"Gt":    comparePredicate(func(a, b float64) bool { return a > b }),
+	"Ge":    comparePredicate(func(a, b float64) bool { return a >= b }),
+	"In":    InPredicate,
+	"Range": RangePredicate,
+	"Null":  NullPredicate,
+}
+
+// MatchPredicate is the Predicate for a "-match field regexp" switch
+func MatchPredicate(args []string) (Condition, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected a pattern, got %d arguments", len(args))
+	}
+	re, err := regexp.Compile(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %w", args[0], err)
+	}
+	return re.MatchString, nil
+}
+
+// comparePredicate returns the Predicate for a "field number" switch that
+// compares the cell, as a number, with the argument
+func comparePredicate(compare func(cell, value float64) bool) Predicate {
+	return func(args []string) (Condition, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected a number, got %d arguments", len(args))
+		}
+		value, err := parseNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(cell string) bool {
+			number, ok := cellNumber(cell)
+			return ok && compare(number, value)
+		}, nil
+	}
+}
+
+// InPredicate is the Predicate for a "-in field a,b,c" switch
+func InPredicate(args []string) (Condition, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected comma-separated values, got %d arguments", len(args))
+	}
+	values := make(map[string]bool)
+	for _, value := range strings.Split(args[0], ",") {
+		values[strings.TrimSpace(value)] = true
+	}
+	return func(cell string) bool {
+		return values[strings.TrimSpace(cell)]
+	}, nil
+}
+
+// RangePredicate is the Predicate for a "-range field low high" switch
+func RangePredicate(args []string) (Condition, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected low and high, got %d arguments", len(args))
+	}
+	low, err := parseNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
+	high, err := parseNumber(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if low > high {
+		return nil, fmt.Errorf("empty range: low %s is above high %s", args[0], args[1])
+	}
+	return func(cell string) bool {
+		number, ok := cellNumber(cell)
+		return ok && number >= low && number <= high
+	}, nil
+}
+
+// NullPredicate is the Predicate for a "-null field" switch, matching empty
+// cells and the usual missing-value markers
+func NullPredicate(args []string) (Condition, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("expected no arguments after the field, got %d", len(args))
+	}
+	return tsv.IsNull, nil
+}
+
+// cellNumber parses a cell as a number, ignoring surrounding space
+func cellNumber(cell string) (float64, bool) {
+	number, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+	return number, err == nil
+}
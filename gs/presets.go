@@ -0,0 +1,119 @@
+package gs
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// presetFlag names a preset to expand, as does @@name
+const presetFlag = "-preset"
+
+// presetSection is the config file section holding presets
+const presetSection = "presets"
+
+// preset is a named set of arguments saved in a config file
+type preset struct {
+	value  interface{} // A string split like a response file, or a JSON array of arguments
+	source string      // The config file defining it
+}
+
+// args returns the arguments the preset expands to
+func (p preset) args() ([]string, error) {
+	switch v := p.value.(type) {
+	case string:
+		return splitArguments(v)
+	case []interface{}:
+		args := make([]string, len(v))
+		for i, arg := range v {
+			text, ok := configText(arg)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of arguments")
+			}
+			args[i] = text
+		}
+		return args, nil
+	}
+	return nil, fmt.Errorf("expected a string of arguments")
+}
+
+// String returns the preset's arguments as they would be typed, or its text
+// if it is invalid
+func (p preset) String() string {
+	args, err := p.args()
+	if err != nil {
+		return fmt.Sprint(p.value)
+	}
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = quoteArg(arg)
+	}
+	return strings.Join(words, " ")
+}
+
+// presets returns the presets in the [presets] sections of the command's
+// config file and the nearest .gsrc, which overrides it. Errors reading the
+// files are reported with the defaults they hold.
+func (cmd *GSCommand) presets() map[string]preset {
+	presets := make(map[string]preset)
+	sources, _ := cmd.configSources()
+	for _, source := range sources {
+		for name, value := range source.presets {
+			presets[name] = preset{value: value, source: source.path}
+		}
+	}
+	return presets
+}
+
+// presetNames returns the names of presets in order
+func presetNames(presets map[string]preset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// usesPresetFlag reports whether -preset names a preset rather than being a
+// switch of the command
+func (cmd *GSCommand) usesPresetFlag() bool {
+	return cmd.configField(presetFlag[1:]) == nil
+}
+
+// isPresetRef reports whether arg names a preset, @@name
+func isPresetRef(arg string) bool {
+	return len(arg) > 2 && strings.HasPrefix(arg, "@@")
+}
+
+// expandPreset returns the arguments of the preset called name
+func expandPreset(presets map[string]preset, name string) ([]string, error) {
+	p, ok := presets[name]
+	if !ok {
+		msg := "unknown preset: " + name
+		if suggestion := Suggest(name, presetNames(presets)); suggestion != "" {
+			msg += fmt.Sprintf("; did you mean %s?", suggestion)
+		}
+		return nil, fmt.Errorf("%s", msg)
+	}
+	args, err := p.args()
+	if err != nil {
+		return nil, fmt.Errorf("preset %s in %s: %w", name, p.source, err)
+	}
+	return args, nil
+}
+
+// completePresets completes preset names, keeping the @@ of an @@name
+func (cmd *GSCommand) completePresets(partial string) []string {
+	prefix := ""
+	if strings.HasPrefix(partial, "@@") {
+		prefix, partial = "@@", partial[2:]
+	}
+	var matches []string
+	for _, name := range presetNames(cmd.presets()) {
+		if strings.HasPrefix(strings.ToLower(name), strings.ToLower(partial)) {
+			matches = append(matches, prefix+name)
+		}
+	}
+	return matches
+}
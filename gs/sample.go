@@ -0,0 +1,109 @@
+package gs
+
+import (
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rosscartlidge/gogstools/gs/tsv"
+)
+
+const (
+	// depthEnv overrides the number of values sampled for content completion
+	depthEnv = "GS_SCAN_DEPTH"
+
+	// budgetEnv overrides the time content completion may spend reading a
+	// file, as a Go duration such as "500ms"
+	budgetEnv = "GS_SCAN_BUDGET"
+
+	// defaultScanBudget keeps completion responsive on large files
+	defaultScanBudget = 250 * time.Millisecond
+)
+
+// SetScanDepth sets how many values content completion samples from a field,
+// for switches without a sample= tag. GS_SCAN_DEPTH overrides it.
+func (cmd *GSCommand) SetScanDepth(depth int) {
+	if depth > 0 {
+		cmd.scanDepth = depth
+	}
+}
+
+// SetScanBudget sets how long content completion may spend reading a file;
+// values are sampled from as much of the file as was read. GS_SCAN_BUDGET
+// overrides it.
+func (cmd *GSCommand) SetScanBudget(budget time.Duration) {
+	if budget > 0 {
+		cmd.scanBudget = budget
+	}
+}
+
+// sampleDepth returns the number of values to sample for completing an
+// argument of the switch meta (which may be nil): GS_SCAN_DEPTH if set, then
+// the switch's sample= tag, then the command's scan depth
+func (cmd *GSCommand) sampleDepth(meta *FieldMeta) int {
+	if depth, err := strconv.Atoi(os.Getenv(depthEnv)); err == nil && depth > 0 {
+		return depth
+	}
+	if meta != nil && meta.Sample > 0 {
+		return meta.Sample
+	}
+	return cmd.scanDepth
+}
+
+// sampleBudget returns how long content completion may spend reading a file
+func (cmd *GSCommand) sampleBudget() time.Duration {
+	if budget, err := time.ParseDuration(os.Getenv(budgetEnv)); err == nil && budget > 0 {
+		return budget
+	}
+	return cmd.scanBudget
+}
+
+// sampleValues reads records until the input ends or budget runs out, keeping
+// a uniform reservoir sample of depth non-empty values of the column so that
+// values appearing late in a file, such as in a time-ordered log, are
+// represented. It returns the distinct values most frequent first.
+func sampleValues(reader *tsv.Reader, column, depth int, budget time.Duration) []string {
+	// A fixed seed keeps completion stable between TAB presses
+	random := rand.New(rand.NewPCG(1, uint64(column)))
+	deadline := time.Now().Add(budget)
+
+	reservoir := make([]string, 0, depth)
+	seen := 0
+	for rows := 1; ; rows++ {
+		record, err := reader.Read()
+		if err != nil {
+			break // EOF or a malformed line ends the sample
+		}
+		if column < len(record) && record[column] != "" {
+			seen++
+			if len(reservoir) < depth {
+				reservoir = append(reservoir, record[column])
+			} else if i := random.IntN(seen); i < depth {
+				reservoir[i] = record[column]
+			}
+		}
+
+		// Checking the clock is comparatively slow, so only do it now and then
+		if rows%256 == 0 && time.Now().After(deadline) {
+			break
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, value := range reservoir {
+		counts[value]++
+	}
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	return values
+}
@@ -0,0 +1,175 @@
+package gs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultWordBreaks is bash's default COMP_WORDBREAKS
+const defaultWordBreaks = " \t\n\"'><=;|&(:"
+
+// splitCommandLine splits a bash command line into words the way the shell
+// does, removing quotes and backslash escapes. The last word is the one being
+// completed, and is empty if line ends in whitespace. quote is the quote left
+// open in it, if any, and start is the offset in it at which readline begins
+// the text it replaces: after an open quote, or else after the last of breaks
+// outside quotes.
+func splitCommandLine(line, breaks string) (words []string, quote byte, start int) {
+	var word strings.Builder
+	inWord := false
+	breakStart, quoteStart := 0, 0
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteByte(c)
+			}
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '\\' && i+1 < len(line) && strings.IndexByte("\\\"$`\n", line[i+1]) >= 0 {
+				i++
+				if line[i] != '\n' {
+					word.WriteByte(line[i])
+				}
+			} else {
+				word.WriteByte(c)
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < len(line) {
+				i++
+				if line[i] != '\n' {
+					word.WriteByte(line[i])
+				}
+			}
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+				breakStart = 0
+			}
+		case c == '\'' || c == '"':
+			inWord = true
+			quote = c
+			quoteStart = word.Len()
+		default:
+			inWord = true
+			word.WriteByte(c)
+			if strings.IndexByte(breaks, c) >= 0 {
+				breakStart = word.Len()
+			}
+		}
+	}
+
+	words = append(words, word.String())
+	start = breakStart
+	if quote != 0 {
+		start = quoteStart
+	}
+	return words, quote, start
+}
+
+// quoteForBash escapes value for insertion into a bash command line, either
+// inside the quote left open by the user or, if quote is 0, as part of an
+// unquoted word. It reports false if value cannot be inserted in that context.
+func quoteForBash(value string, quote byte) (string, bool) {
+	var b strings.Builder
+	for _, r := range value {
+		if r < ' ' || r == 0x7f {
+			// Control characters can only be written with ANSI-C quoting
+			if quote != 0 {
+				return "", false
+			}
+			fmt.Fprintf(&b, `$'\x%02x'`, r)
+			continue
+		}
+		switch quote {
+		case '\'':
+			if r == '\'' {
+				b.WriteString(`'\''`)
+				continue
+			}
+		case '"':
+			if strings.ContainsRune("\"\\$`", r) {
+				b.WriteByte('\\')
+			}
+		default:
+			if r < utf8.RuneSelf && !isShellSafe(byte(r)) {
+				b.WriteByte('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), true
+}
+
+// isShellSafe reports whether c needs no escaping in an unquoted bash word
+func isShellSafe(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("_-./:=@%+,", c) >= 0
+}
+
+// writeBashCandidates prints the candidates from describe for -complete-bash
+// in the format of -complete-v1, with each value escaped so that bash can
+// insert it in place of the text readline replaces. line and point are bash's
+// COMP_LINE and COMP_POINT; the line is split by the command rather than by
+// bash so that quoted words and words containing COMP_WORDBREAKS characters
+// survive.
+func writeBashCandidates(w io.Writer, line string, point int, describe describeFunc) error {
+	breaks, ok := os.LookupEnv("COMP_WORDBREAKS")
+	if !ok {
+		breaks = defaultWordBreaks
+	}
+	point = max(0, min(point, len(line)))
+
+	words, quote, start := splitCommandLine(line[:point], breaks)
+	if len(words) < 2 {
+		return nil // Completing the command name itself
+	}
+
+	// Words after the cursor, without the rest of the word being completed
+	following, _, _ := splitCommandLine(line[point:], breaks)
+	if rest := line[point:]; rest == "" || !strings.ContainsRune(" \t\n", rune(rest[0])) {
+		following = following[1:]
+	}
+	if len(following) > 0 && following[len(following)-1] == "" {
+		following = following[:len(following)-1]
+	}
+
+	current := words[len(words)-1]
+	args := append(words[1:], following...)
+	candidates, err := describe(args, len(words)-2)
+	if err != nil {
+		return err
+	}
+
+	// Readline keeps the text before start, so strip it from each candidate
+	fixed := current[:start]
+	var escaped []Candidate
+	for _, candidate := range candidates {
+		if len(candidate.Value) < len(fixed) || !strings.EqualFold(candidate.Value[:len(fixed)], fixed) {
+			continue
+		}
+		value, ok := quoteForBash(candidate.Value[len(fixed):], quote)
+		if !ok {
+			continue
+		}
+		candidate.Value = value
+		escaped = append(escaped, candidate)
+	}
+
+	writeCandidates(w, escaped)
+	return nil
+}
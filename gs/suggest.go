@@ -0,0 +1,72 @@
+package gs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Suggest returns the candidate closest to input by edit distance, or "" if
+// none is close enough to be a plausible typo. Comparison ignores case.
+func Suggest(input string, candidates []string) string {
+	input = strings.ToLower(input)
+
+	// Allow roughly one edit per three characters, and at least one, but never
+	// so many that the whole input is replaced ("x" is not a typo for "y")
+	limit := len(input) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	if limit >= len([]rune(input)) {
+		limit = len([]rune(input)) - 1
+	}
+
+	best, bestDistance := "", limit+1
+	for _, candidate := range candidates {
+		distance := editDistance(input, strings.ToLower(candidate))
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// UnknownFieldError reports a field name missing from a header, suggesting
+// the closest field, e.g. "unknown field 'cpu_usge' in data.tsv; did you mean 'cpu_usage'?"
+func UnknownFieldError(name, source string, header []string) error {
+	msg := fmt.Sprintf("unknown field '%s'", name)
+	if source != "" {
+		msg += " in " + source
+	}
+	if suggestion := Suggest(name, header); suggestion != "" {
+		msg += fmt.Sprintf("; did you mean '%s'?", suggestion)
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// editDistance computes the Damerau-Levenshtein (optimal string alignment)
+// distance, counting an adjacent transposition such as "hieght" as one edit
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
@@ -0,0 +1,139 @@
+package tsv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Decompressor describes a compression format that Open and Decompress
+// recognise, by file name suffix and by the magic bytes that start its data
+type Decompressor struct {
+	Name      string                 // Format name for errors, e.g. "gzip"
+	Suffix    string                 // File name suffix, e.g. ".gz"
+	Magic     []byte                 // Leading bytes of compressed data
+	Detect    func(lead []byte) bool // Optional check of Magic and the byte after it
+	NewReader func(io.Reader) (io.ReadCloser, error)
+}
+
+// decompressors holds the registered formats; zstd is added by building with
+// -tags zstd, but its suffix and magic are always known so that the error
+// for unsupported input is clear
+var decompressors = []Decompressor{
+	{
+		Name:   "gzip",
+		Suffix: ".gz",
+		Magic:  []byte{0x1f, 0x8b},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		Name:   "bzip2",
+		Suffix: ".bz2",
+		Magic:  []byte("BZh"),
+		Detect: func(lead []byte) bool {
+			// "BZh" is followed by the block size, 1-9
+			return len(lead) > 3 && '1' <= lead[3] && lead[3] <= '9'
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+	{
+		Name:   "zstd",
+		Suffix: ".zst",
+		Magic:  []byte{0x28, 0xb5, 0x2f, 0xfd},
+		NewReader: func(io.Reader) (io.ReadCloser, error) {
+			return nil, fmt.Errorf("zstd input is not supported by this build (build with -tags zstd)")
+		},
+	},
+}
+
+// RegisterDecompressor adds a compression format, replacing any registered
+// format with the same suffix
+func RegisterDecompressor(d Decompressor) {
+	for i := range decompressors {
+		if decompressors[i].Suffix == d.Suffix {
+			decompressors[i] = d
+			return
+		}
+	}
+	decompressors = append(decompressors, d)
+}
+
+// TrimCompression returns name without a compression suffix, so that
+// "data.tsv.gz" gives "data.tsv"
+func TrimCompression(name string) string {
+	lower := strings.ToLower(name)
+	for _, d := range decompressors {
+		if strings.HasSuffix(lower, d.Suffix) {
+			return name[:len(name)-len(d.Suffix)]
+		}
+	}
+	return name
+}
+
+// IsDataFile reports whether name is a TSV or CSV file, possibly compressed
+func IsDataFile(name string) bool {
+	base := strings.ToLower(TrimCompression(name))
+	return strings.HasSuffix(base, ".tsv") || strings.HasSuffix(base, ".csv")
+}
+
+// Open opens a file for reading, decompressing it if it is compressed in a
+// registered format. Closing the result closes the file.
+func Open(name string) (io.ReadCloser, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("opening file %s: %w", name, err)
+	}
+
+	reader, err := Decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	return readCloser{Reader: reader, closers: []io.Closer{reader, file}}, nil
+}
+
+// Decompress returns a reader of the decompressed data if r starts with the
+// magic bytes of a registered format, and of r unchanged otherwise
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	for _, d := range decompressors {
+		n := len(d.Magic)
+		if d.Detect != nil {
+			n++
+		}
+		lead, _ := buffered.Peek(n)
+		if bytes.HasPrefix(lead, d.Magic) && (d.Detect == nil || d.Detect(lead)) {
+			reader, err := d.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", d.Name, err)
+			}
+			return reader, nil
+		}
+	}
+	return io.NopCloser(buffered), nil
+}
+
+// readCloser closes a decompressor and then the file under it
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (rc readCloser) Close() error {
+	var first error
+	for _, closer := range rc.closers {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,26 @@
+//go:build zstd
+
+package tsv
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Decompress zstd with klauspost/compress, which only builds that use
+// -tags zstd depend on
+func init() {
+	RegisterDecompressor(Decompressor{
+		Name:   "zstd",
+		Suffix: ".zst",
+		Magic:  []byte{0x28, 0xb5, 0x2f, 0xfd},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			decoder, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return decoder.IOReadCloser(), nil
+		},
+	})
+}
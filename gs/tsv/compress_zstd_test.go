@@ -0,0 +1,21 @@
+//go:build zstd
+
+package tsv
+
+import (
+	"io"
+	"testing"
+)
+
+func TestZstdInput(t *testing.T) {
+	input, err := Open("testdata/sample.tsv.zst")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer input.Close()
+
+	data, err := io.ReadAll(input)
+	if err != nil || string(data) != "time\tcpu\n1\t25\n2\t35\n" {
+		t.Errorf("Unexpected zstd data %q (%v)", data, err)
+	}
+}
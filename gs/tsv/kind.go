@@ -0,0 +1,174 @@
+package tsv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type of the values in a column, inferred from a sample of them
+type Kind int
+
+const (
+	Text      Kind = iota // Free text
+	Int                   // Integers
+	Float                 // Numbers, at least one of them not an integer
+	Bool                  // true/false or yes/no
+	Timestamp             // Dates and times, e.g. 2006-01-02 or RFC 3339
+	Enum                  // Text with few distinct, repeated values
+)
+
+// EnumLimit is the most distinct values a column of text may have to be
+// inferred as an Enum
+const EnumLimit = 16
+
+var kindNames = [...]string{
+	Text:      "text",
+	Int:       "int",
+	Float:     "float",
+	Bool:      "bool",
+	Timestamp: "timestamp",
+	Enum:      "enum",
+}
+
+// timestampLayouts are the time formats recognised in Timestamp columns
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	"15:04:05",
+	"15:04",
+}
+
+func (k Kind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Numeric reports whether values of the kind are numbers
+func (k Kind) Numeric() bool {
+	return k == Int || k == Float
+}
+
+// ParseKind returns the Kind named s, as returned by Kind.String
+func ParseKind(s string) (Kind, error) {
+	for k, name := range kindNames {
+		if name == s {
+			return Kind(k), nil
+		}
+	}
+	return Text, fmt.Errorf("unknown kind: %s", s)
+}
+
+// MarshalText encodes the kind as its name
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a kind from its name
+func (k *Kind) UnmarshalText(text []byte) error {
+	kind, err := ParseKind(string(text))
+	if err != nil {
+		return err
+	}
+	*k = kind
+	return nil
+}
+
+// IsNull reports whether a cell holds no value: it is empty or NA, N/A or
+// NULL in any case
+func IsNull(cell string) bool {
+	switch strings.ToUpper(strings.TrimSpace(cell)) {
+	case "", "NA", "N/A", "NULL":
+		return true
+	}
+	return false
+}
+
+// InferKind infers the kind of a column from a sample of its values, ignoring
+// null cells. The kind is the first of Int, Float, Bool and Timestamp that
+// every value parses as; otherwise a column with at most EnumLimit distinct
+// values, each seen twice on average, is an Enum and any other is Text.
+func InferKind(values []string) Kind {
+	var cells []string
+	for _, value := range values {
+		if !IsNull(value) {
+			cells = append(cells, strings.TrimSpace(value))
+		}
+	}
+	if len(cells) == 0 {
+		return Text
+	}
+
+	for _, kind := range []Kind{Int, Float, Bool, Timestamp} {
+		if allParse(cells, kind) {
+			return kind
+		}
+	}
+
+	distinct := make(map[string]bool)
+	for _, cell := range cells {
+		distinct[cell] = true
+	}
+	if len(distinct) <= EnumLimit && 2*len(distinct) <= len(cells) {
+		return Enum
+	}
+	return Text
+}
+
+// InferKinds infers the kind of each column of header from records
+func InferKinds(header Header, records []Record) []Kind {
+	kinds := make([]Kind, len(header))
+	column := make([]string, len(records))
+	for i := range header {
+		for j, record := range records {
+			column[j] = ""
+			if i < len(record) {
+				column[j] = record[i]
+			}
+		}
+		kinds[i] = InferKind(column)
+	}
+	return kinds
+}
+
+// allParse reports whether every cell parses as a value of kind
+func allParse(cells []string, kind Kind) bool {
+	for _, cell := range cells {
+		if !parses(cell, kind) {
+			return false
+		}
+	}
+	return true
+}
+
+func parses(cell string, kind Kind) bool {
+	switch kind {
+	case Int:
+		_, err := strconv.ParseInt(cell, 10, 64)
+		return err == nil
+	case Float:
+		_, err := strconv.ParseFloat(cell, 64)
+		return err == nil
+	case Bool:
+		switch strings.ToLower(cell) {
+		case "true", "false", "yes", "no":
+			return true
+		}
+		return false
+	case Timestamp:
+		for _, layout := range timestampLayouts {
+			if _, err := time.Parse(layout, cell); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
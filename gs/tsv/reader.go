@@ -0,0 +1,294 @@
+// Package tsv reads and writes the tab- and comma-separated files processed by
+// gs commands: a header line of field names, then one record per line.
+//
+// Comma-separated fields may be quoted CSV-style ("a, b" or "say ""hi"""),
+// which allows separators, quotes and newlines inside values; with any other
+// separator quotes are ordinary text. Lines starting with "# " or "##" before
+// the header are annotations and are skipped; the header line may itself start
+// with a "#" marker, as in "#time<TAB>cpu", which is stripped.
+package tsv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Header holds the field names from the first line of a file
+type Header []string
+
+// Index returns the position of a field, or -1 if it is not present
+func (h Header) Index(name string) int {
+	for i, field := range h {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Has reports whether a field is present
+func (h Header) Has(name string) bool {
+	return h.Index(name) != -1
+}
+
+// Record holds the values of one line
+type Record []string
+
+// Get returns the value of a field, or "" if the field is unknown or the record is short
+func (r Record) Get(h Header, name string) string {
+	if i := h.Index(name); i >= 0 && i < len(r) {
+		return r[i]
+	}
+	return ""
+}
+
+// separators are tried in order when sniffing the header line
+var separators = []rune{'\t', ',', ';', '|'}
+
+// Reader reads records from a TSV or CSV stream
+type Reader struct {
+	// Comma is the field separator. If zero it is detected from the header
+	// line, preferring tab, then comma, semicolon and bar.
+	Comma rune
+
+	// Comment starts annotation lines before the header; zero disables
+	// annotations
+	Comment rune
+
+	in          *bufio.Reader
+	header      Header
+	headerRead  bool
+	annotations []string
+	line        int
+}
+
+// NewReader returns a Reader that sniffs the separator and skips "#" annotations
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		Comment: '#',
+		in:      bufio.NewReader(r),
+	}
+}
+
+// ReadHeader reads just the header from r
+func ReadHeader(r io.Reader) (Header, error) {
+	return NewReader(r).Header()
+}
+
+// Header returns the header, reading it if no records have been read yet
+func (r *Reader) Header() (Header, error) {
+	if r.headerRead {
+		return r.header, nil
+	}
+	r.headerRead = true
+
+	line, err := r.nextLine(true)
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("no header: input is empty")
+		}
+		return nil, err
+	}
+
+	if r.Comment != 0 {
+		line = strings.TrimPrefix(line, string(r.Comment))
+	}
+	if r.Comma == 0 {
+		r.Comma = sniffSeparator(line)
+	}
+
+	fields, err := r.splitRecord(line)
+	if err != nil {
+		return nil, err
+	}
+	r.header = Header(fields)
+	return r.header, nil
+}
+
+// Read returns the next record, reading the header first if necessary.
+// It returns io.EOF at the end of the input.
+func (r *Reader) Read() (Record, error) {
+	if _, err := r.Header(); err != nil {
+		return nil, err
+	}
+
+	line, err := r.nextLine(false)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := r.splitRecord(line)
+	if err != nil {
+		return nil, err
+	}
+	return Record(fields), nil
+}
+
+// ReadAll reads the header and all remaining records
+func (r *Reader) ReadAll() (Header, []Record, error) {
+	header, err := r.Header()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []Record
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return header, records, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// Annotations returns the text of the annotation lines read so far, without
+// their comment marker
+func (r *Reader) Annotations() []string {
+	return r.annotations
+}
+
+// Line returns the number of the last line read
+func (r *Reader) Line() int {
+	return r.line
+}
+
+// nextLine returns the next line that is not blank or, before the header, an
+// annotation. A line of whitespace is blank before the header and in files
+// whose separator isn't whitespace; in TSV it is a record of empty fields.
+func (r *Reader) nextLine(beforeHeader bool) (string, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return "", err
+		}
+		if line == "" || (strings.TrimSpace(line) == "" && (beforeHeader || !r.spaceSeparated())) {
+			continue
+		}
+		if beforeHeader && r.isAnnotation(line) {
+			text := strings.TrimLeft(line, string(r.Comment))
+			r.annotations = append(r.annotations, strings.TrimSpace(text))
+			continue
+		}
+		return line, nil
+	}
+}
+
+// readLine reads one physical line without its line ending
+func (r *Reader) readLine() (string, error) {
+	line, err := r.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	r.line++
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// isAnnotation reports whether a line is a comment rather than data. A marker
+// followed directly by text, as in "#time<TAB>cpu", is a header marker or
+// data, not a comment.
+func (r *Reader) isAnnotation(line string) bool {
+	if r.Comment == 0 || !strings.HasPrefix(line, string(r.Comment)) {
+		return false
+	}
+	rest := line[len(string(r.Comment)):]
+	return rest == "" || strings.HasPrefix(rest, " ") || strings.HasPrefix(rest, string(r.Comment))
+}
+
+// spaceSeparated reports whether the separator is whitespace, so that spaces
+// around fields are part of their values
+func (r *Reader) spaceSeparated() bool {
+	return r.Comma == '\t' || r.Comma == ' '
+}
+
+// splitRecord splits a line into fields. Only comma-separated fields may be
+// quoted, reading further lines when a quoted field spans a line break.
+func (r *Reader) splitRecord(line string) ([]string, error) {
+	if r.Comma != ',' {
+		fields := strings.Split(line, string(r.Comma))
+		if !r.spaceSeparated() {
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+		}
+		return fields, nil
+	}
+
+	var fields []string
+	var field strings.Builder
+	startLine := r.line
+
+	i := 0
+	for {
+		// Skip leading spaces
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+
+		if i < len(line) && line[i] == '"' {
+			// Quoted field: read up to the closing quote, "" is a literal quote
+			i++
+			for {
+				end := strings.IndexByte(line[i:], '"')
+				if end < 0 {
+					// The field continues on the next line
+					field.WriteString(line[i:])
+					field.WriteByte('\n')
+					next, err := r.readLine()
+					if err == io.EOF {
+						return nil, fmt.Errorf("line %d: unterminated quoted field", startLine)
+					}
+					if err != nil {
+						return nil, err
+					}
+					line, i = next, 0
+					continue
+				}
+				field.WriteString(line[i : i+end])
+				i += end + 1
+				if i < len(line) && line[i] == '"' {
+					field.WriteByte('"')
+					i++
+					continue
+				}
+				break
+			}
+			// Only spaces may come between the closing quote and the separator
+			next := strings.IndexRune(line[i:], r.Comma)
+			if next < 0 {
+				next = len(line) - i
+			}
+			if strings.TrimSpace(line[i:i+next]) != "" {
+				return nil, fmt.Errorf("line %d: unexpected text after quoted field: %q", r.line, line[i:i+next])
+			}
+			i += next
+			fields = append(fields, field.String())
+			field.Reset()
+		} else {
+			end := strings.IndexRune(line[i:], r.Comma)
+			if end < 0 {
+				end = len(line) - i
+			}
+			fields = append(fields, strings.TrimSpace(line[i:i+end]))
+			i += end
+		}
+
+		if i >= len(line) {
+			return fields, nil
+		}
+		i += len(string(r.Comma)) // Skip the separator
+	}
+}
+
+// sniffSeparator picks the separator used by a header line
+func sniffSeparator(line string) rune {
+	for _, sep := range separators {
+		if strings.ContainsRune(line, sep) {
+			return sep
+		}
+	}
+	return '\t'
+}
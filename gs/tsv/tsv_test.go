@@ -0,0 +1,292 @@
+package tsv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReaderSeparators(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		header  Header
+		records []Record
+	}{
+		{
+			name:    "tab separated",
+			input:   "time\tcpu\n1\t25\n2\t35\n",
+			header:  Header{"time", "cpu"},
+			records: []Record{{"1", "25"}, {"2", "35"}},
+		},
+		{
+			name:    "comma separated with spaces",
+			input:   "time, cpu\n1, 25\n",
+			header:  Header{"time", "cpu"},
+			records: []Record{{"1", "25"}},
+		},
+		{
+			name:    "semicolon separated",
+			input:   "a;b\nx;y\n",
+			header:  Header{"a", "b"},
+			records: []Record{{"x", "y"}},
+		},
+		{
+			name:    "header marker, CRLF and blank lines",
+			input:   "#time\tcpu\r\n\r\n1\t25\r\n",
+			header:  Header{"time", "cpu"},
+			records: []Record{{"1", "25"}},
+		},
+		{
+			name:    "no trailing newline",
+			input:   "a\n1",
+			header:  Header{"a"},
+			records: []Record{{"1"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header, records, err := NewReader(strings.NewReader(test.input)).ReadAll()
+			if err != nil {
+				t.Fatalf("ReadAll failed: %v", err)
+			}
+			if !reflect.DeepEqual(header, test.header) {
+				t.Errorf("Expected header %q, got %q", test.header, header)
+			}
+			if !reflect.DeepEqual(records, test.records) {
+				t.Errorf("Expected records %q, got %q", test.records, records)
+			}
+		})
+	}
+}
+
+func TestReaderQuotingAndAnnotations(t *testing.T) {
+	input := strings.Join([]string{
+		"# generated by test",
+		"## units: none",
+		"city,note,count",
+		`"New York","says ""hi"", twice",3`,
+		`"multi`,
+		`line",,`,
+		`#1,literal,5`,
+		`# of items,after header,6`,
+	}, "\n")
+
+	reader := NewReader(strings.NewReader(input))
+	header, records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(header, Header{"city", "note", "count"}) {
+		t.Errorf("Unexpected header %q", header)
+	}
+	expected := []Record{
+		{"New York", `says "hi", twice`, "3"},
+		{"multi\nline", "", ""},
+		{"#1", "literal", "5"},
+		{"# of items", "after header", "6"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected records %q, got %q", expected, records)
+	}
+	if !reflect.DeepEqual(reader.Annotations(), []string{"generated by test", "units: none"}) {
+		t.Errorf("Unexpected annotations %q", reader.Annotations())
+	}
+	if records[0].Get(header, "count") != "3" || records[0].Get(header, "missing") != "" {
+		t.Errorf("Record.Get returned unexpected values")
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	if _, err := ReadHeader(strings.NewReader("")); err == nil {
+		t.Errorf("Expected error for empty input")
+	}
+
+	reader := NewReader(strings.NewReader("a,b\n\"open,1\n"))
+	if _, err := reader.Read(); err == nil || err == io.EOF {
+		t.Errorf("Expected unterminated quote error, got %v", err)
+	}
+
+	reader = NewReader(strings.NewReader("a,b\n\"closed\"text,1\n"))
+	if _, err := reader.Read(); err == nil || !strings.Contains(err.Error(), `after quoted field: "text"`) {
+		t.Errorf("Expected error for text after a closing quote, got %v", err)
+	}
+}
+
+func TestReaderPlainTSV(t *testing.T) {
+	// Quotes are text, spaces are kept, and a row of empty fields is a record
+	input := "a\tb\tc\n1\t2\t3\n\t\t\n\"open\t \"x\" \t6\n"
+	_, records, err := NewReader(strings.NewReader(input)).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	expected := []Record{{"1", "2", "3"}, {"", "", ""}, {`"open`, ` "x" `, "6"}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected records %q, got %q", expected, records)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	header := Header{"#name", "value"}
+	records := []Record{
+		{"plain", "1"},
+		{"New, York", `quote "here"`},
+		{"#hash", " padded "},
+		{"multi\nline", ""},
+		{"", ""},
+	}
+
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	writer.Comma = ','
+
+	if err := writer.WriteComment("written by test"); err != nil {
+		t.Fatalf("WriteComment failed: %v", err)
+	}
+	if err := writer.WriteHeader(header); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	reader := NewReader(&buf)
+	gotHeader, gotRecords, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotHeader, header) || !reflect.DeepEqual(gotRecords, records) {
+		t.Errorf("Round trip mismatch: got %q %q", gotHeader, gotRecords)
+	}
+	if !reflect.DeepEqual(reader.Annotations(), []string{"written by test"}) {
+		t.Errorf("Unexpected annotations %q", reader.Annotations())
+	}
+
+	// TSV has no quoting: values it can hold are written as they are
+	buf.Reset()
+	writer = NewWriter(&buf)
+	writer.WriteHeader(Header{"a", "b"})
+	writer.Write(Record{`say "hi"`, " padded "})
+	writer.Write(Record{"", ""})
+	writer.Flush()
+	if buf.String() != "a\tb\nsay \"hi\"\t padded \n\t\n" {
+		t.Errorf("Unexpected TSV output %q", buf.String())
+	}
+	_, gotRecords, err = NewReader(&buf).ReadAll()
+	if err != nil || !reflect.DeepEqual(gotRecords, []Record{{`say "hi"`, " padded "}, {"", ""}}) {
+		t.Errorf("TSV round trip mismatch: got %q (%v)", gotRecords, err)
+	}
+	if err := writer.Write(Record{"New\tYork"}); err == nil {
+		t.Errorf("Expected error writing a tab in a TSV field")
+	}
+}
+
+func TestCompressedInput(t *testing.T) {
+	dir := t.TempDir()
+	plain := "time\tcpu\n1\t25\n2\t35\n"
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(plain))
+	gz.Close()
+	gzName := filepath.Join(dir, "sample.tsv.gz")
+	if err := os.WriteFile(gzName, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plainName := filepath.Join(dir, "sample.csv")
+	if err := os.WriteFile(plainName, []byte(plain), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{gzName, "testdata/sample.tsv.bz2", plainName} {
+		input, err := Open(name)
+		if err != nil {
+			t.Fatalf("Open %s failed: %v", name, err)
+		}
+		data, err := io.ReadAll(input)
+		input.Close()
+		if err != nil || string(data) != plain {
+			t.Errorf("Expected %s to decompress to %q, got %q (%v)", name, plain, data, err)
+		}
+	}
+
+	// Data piped in is recognised by its magic bytes rather than a name
+	input, err := Decompress(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Decompress failed: %v", err)
+	}
+	if header, err := ReadHeader(input); err != nil || !reflect.DeepEqual(header, Header{"time", "cpu"}) {
+		t.Errorf("Unexpected header from gzip stream: %q (%v)", header, err)
+	}
+
+	// Text that merely starts like a bzip2 stream is left alone
+	input, err = Decompress(strings.NewReader("BZhost\tcpu\nweb\t25\n"))
+	if err != nil {
+		t.Fatalf("Decompress failed: %v", err)
+	}
+	if header, err := ReadHeader(input); err != nil || !reflect.DeepEqual(header, Header{"BZhost", "cpu"}) {
+		t.Errorf("Unexpected header from plain text: %q (%v)", header, err)
+	}
+
+	for name, expected := range map[string]bool{
+		"data.tsv": true, "DATA.CSV.GZ": true, "data.tsv.bz2": true, "data.tsv.zst": true,
+		"data.txt.gz": false, "data.gz": false, "tsv": false,
+	} {
+		if IsDataFile(name) != expected {
+			t.Errorf("IsDataFile(%q) = %v, expected %v", name, !expected, expected)
+		}
+	}
+	if TrimCompression("logs/data.tsv.gz") != "logs/data.tsv" {
+		t.Errorf("Unexpected TrimCompression result %q", TrimCompression("logs/data.tsv.gz"))
+	}
+}
+
+func TestInferKind(t *testing.T) {
+	tests := []struct {
+		values []string
+		kind   Kind
+	}{
+		{[]string{"1", "-2", " 30 ", "NA", ""}, Int},
+		{[]string{"1", "2.5", "1e3"}, Float},
+		{[]string{"true", "No", "YES", "null"}, Bool},
+		{[]string{"2024-01-02", "2024-01-03T10:00:00Z", "2024-01-04 12:30"}, Timestamp},
+		{[]string{"web", "db", "web", "db", "web"}, Enum},
+		{[]string{"web", "db", "cache"}, Text},
+		{[]string{"New York", "12", "true"}, Text},
+		{[]string{"", "N/A"}, Text},
+	}
+	for _, tt := range tests {
+		if kind := InferKind(tt.values); kind != tt.kind {
+			t.Errorf("InferKind(%q) = %v, expected %v", tt.values, kind, tt.kind)
+		}
+	}
+
+	header := Header{"host", "cpu", "up"}
+	records := []Record{{"web", "25", "yes"}, {"web", "35.5"}, {"db", "", "no"}, {"db", "40", "yes"}}
+	if kinds := InferKinds(header, records); !reflect.DeepEqual(kinds, []Kind{Enum, Float, Bool}) {
+		t.Errorf("Unexpected kinds %v", kinds)
+	}
+
+	for k := Text; k <= Enum; k++ {
+		text, _ := k.MarshalText()
+		var parsed Kind
+		if err := parsed.UnmarshalText(text); err != nil || parsed != k {
+			t.Errorf("Kind %v did not round-trip through %q", k, text)
+		}
+	}
+	if _, err := ParseKind("numeric"); err == nil {
+		t.Errorf("Expected an error for an unknown kind")
+	}
+}
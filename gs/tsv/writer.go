@@ -0,0 +1,94 @@
+package tsv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Writer writes records in the format read by Reader
+type Writer struct {
+	Comma   rune // Field separator, tab by default
+	Comment rune // Annotation marker, '#' by default
+
+	out *bufio.Writer
+}
+
+// NewWriter returns a tab-separated Writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		Comma:   '\t',
+		Comment: '#',
+		out:     bufio.NewWriter(w),
+	}
+}
+
+// WriteHeader writes the header line
+func (w *Writer) WriteHeader(header Header) error {
+	return w.write(Record(header), true)
+}
+
+// Write writes one record. Comma-separated fields that would not read back
+// unchanged are quoted; with other separators, which have no quoting, such a
+// field is an error and nothing is written.
+func (w *Writer) Write(record Record) error {
+	return w.write(record, false)
+}
+
+func (w *Writer) write(record Record, header bool) error {
+	for i, field := range record {
+		if w.needsQuotes(field, header && i == 0) && w.Comma != ',' {
+			return fmt.Errorf("cannot write %q: only comma-separated fields can be quoted", field)
+		}
+	}
+
+	for i, field := range record {
+		if i > 0 {
+			if _, err := w.out.WriteRune(w.Comma); err != nil {
+				return err
+			}
+		}
+		if w.needsQuotes(field, header && i == 0) {
+			field = `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+		}
+		if _, err := w.out.WriteString(field); err != nil {
+			return err
+		}
+	}
+	return w.out.WriteByte('\n')
+}
+
+// WriteComment writes an annotation line; multi-line text becomes several lines
+func (w *Writer) WriteComment(text string) error {
+	for _, line := range strings.Split(text, "\n") {
+		if _, err := w.out.WriteString(string(w.Comment) + " " + line + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer
+func (w *Writer) Flush() error {
+	return w.out.Flush()
+}
+
+// needsQuotes reports whether a field must be quoted to survive a round trip;
+// headerStart is set for the first field of the header
+func (w *Writer) needsQuotes(field string, headerStart bool) bool {
+	if field == "" {
+		return false
+	}
+	if strings.ContainsRune(field, w.Comma) || strings.ContainsAny(field, "\r\n") {
+		return true
+	}
+	if w.Comma == ',' && strings.ContainsRune(field, '"') {
+		return true
+	}
+	// Readers trim fields not separated by whitespace, and strip a header marker
+	if w.Comma != '\t' && w.Comma != ' ' && field != strings.TrimSpace(field) {
+		return true
+	}
+	return headerStart && w.Comment != 0 && strings.HasPrefix(field, string(w.Comment))
+}
@@ -2,8 +2,11 @@ package gs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 // FieldType represents the type of a command field
@@ -64,12 +67,16 @@ type FieldMeta struct {
 	Complete     string        // Completion type hint
 	Suffix       string        // File suffix filter for completion (e.g., ".tsv")
 	Enum         []string      // Enumerated values for completion (e.g., ["bar", "line", "area"])
+	Sample       int           // Values sampled for content completion of this switch; 0 for the command default
+	Kind         string        // Kind of column a field switch accepts (e.g., "numeric"); "" for any
 }
 
 // ClauseSet represents a group of related arguments separated by + or -
 type ClauseSet struct {
 	Fields    map[string]interface{} // Parsed field values
 	IsNegated bool                   // Whether this clause is negated (-)
+	
+	meta []FieldMeta // Field metadata from the command that parsed this clause
 }
 
 // Commander interface for command execution
@@ -93,9 +100,10 @@ type DocumentGenerator interface {
 
 // ParseError represents an error in command parsing
 type ParseError struct {
-	Field   string
-	Value   string
-	Message string
+	Field    string
+	Value    string
+	Message  string
+	Position int // 1-based index of the offending argument, 0 if not tied to one
 }
 
 func (e ParseError) Error() string {
@@ -105,13 +113,83 @@ func (e ParseError) Error() string {
 	return e.Message
 }
 
+// ArgumentErrors collects every problem found while parsing a command line.
+// Its Errors are ParseError and ValidationError values, which can be
+// inspected with errors.As.
+type ArgumentErrors struct {
+	Args   []string // The arguments that were parsed
+	Errors []error
+}
+
+func (e ArgumentErrors) Unwrap() []error {
+	return e.Errors
+}
+
+// Error renders the command line once, with a caret line under the argument
+// each positioned error refers to, followed by the unpositioned errors
+func (e ArgumentErrors) Error() string {
+	words := make([]string, len(e.Args))
+	offsets := make([]int, len(e.Args))
+	offset := 0
+	for i, arg := range e.Args {
+		words[i] = quoteArg(arg)
+		offsets[i] = offset
+		offset += len(words[i]) + 1
+	}
+	
+	var positioned, other []string
+	for _, err := range e.Errors {
+		var parseErr ParseError
+		if errors.As(err, &parseErr) && parseErr.Position > 0 && parseErr.Position <= len(e.Args) {
+			i := parseErr.Position - 1
+			caret := strings.Repeat(" ", offsets[i]) + strings.Repeat("^", len(words[i]))
+			if len(e.Errors) == 1 {
+				// A single error is reported first, with just the caret below it
+				return fmt.Sprintf("%s\n  %s\n  %s", err, strings.Join(words, " "), caret)
+			}
+			positioned = append(positioned, caret+" "+err.Error())
+		} else {
+			other = append(other, err.Error())
+		}
+	}
+	
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d errors", len(e.Errors))
+	if len(positioned) > 0 {
+		sb.WriteString("\n  " + strings.Join(words, " "))
+		for _, line := range positioned {
+			sb.WriteString("\n  " + line)
+		}
+	}
+	for _, line := range other {
+		sb.WriteString("\n  " + line)
+	}
+	return sb.String()
+}
+
+// quoteArg quotes an argument for display if it is empty or contains spaces
+func quoteArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
+
 // ValidationError represents a validation error
 type ValidationError struct {
 	Field   string
+	Clause  int // 1-based clause number for clause-local fields, 0 for the whole command
 	Message string
 }
 
 func (e ValidationError) Error() string {
+	if e.Clause > 0 {
+		return fmt.Sprintf("validation error for field %s in clause %d: %s", e.Field, e.Clause, e.Message)
+	}
 	return fmt.Sprintf("validation error for field %s: %s", e.Field, e.Message)
 }
 
@@ -126,7 +204,12 @@ func reflectFields(v interface{}) ([]FieldMeta, error) {
 		return nil, fmt.Errorf("expected struct, got %T", v)
 	}
 	
-	typ := val.Type()
+	return reflectStructFields(val.Type())
+}
+
+// reflectStructFields extracts field metadata from a struct type, including
+// the fields of embedded structs without gs tags such as Predicates
+func reflectStructFields(typ reflect.Type) ([]FieldMeta, error) {
 	fields := make([]FieldMeta, 0, typ.NumField())
 	
 	for i := 0; i < typ.NumField(); i++ {
@@ -134,6 +217,13 @@ func reflectFields(v interface{}) ([]FieldMeta, error) {
 		tag := field.Tag.Get("gs")
 		
 		if tag == "" {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				embedded, err := reflectStructFields(field.Type)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, embedded...)
+			}
 			continue // Skip fields without gs tags
 		}
 		
@@ -142,6 +232,12 @@ func reflectFields(v interface{}) ([]FieldMeta, error) {
 			return nil, fmt.Errorf("parsing field %s: %w", field.Name, err)
 		}
 		
+		if meta.Type == FieldTypeMulti {
+			if err := validateArgStruct(field.Type, meta.Args); err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+		
 		fields = append(fields, meta)
 	}
 	
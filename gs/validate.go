@@ -0,0 +1,140 @@
+package gs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rosscartlidge/gogstools/gs/tsv"
+)
+
+// fieldRef is a field name given on the command line
+type fieldRef struct {
+	Flag     string // Flag that took the field name, e.g. "-y"
+	Name     string // The field name
+	Position int    // 1-based position in the arguments
+	Kind     string // Kind of column the flag accepts, from its kind= option
+}
+
+// SetValidateFields enables checking field-typed values against the header of
+// the input file during Parse. The input is the TSV/CSV file found in the
+// arguments or, when no input is named at all, standard input, whose header is
+// buffered for OpenInput. Other inputs are left unchecked.
+func (cmd *GSCommand) SetValidateFields(validate bool) {
+	cmd.validateFields = validate
+}
+
+// fieldRefs returns the field names taken by the flag at args[0], which has
+// already been parsed successfully; offset is its index in the full arguments
+func (cmd *GSCommand) fieldRefs(args []string, offset int) []fieldRef {
+	flagName := "-" + args[0][1:] // +flag takes the same arguments as -flag
+	for _, fieldMeta := range cmd.fields {
+		if parseFlagName(fieldMeta.Name) != flagName {
+			continue
+		}
+
+		switch fieldMeta.Type {
+		case FieldTypeField:
+			return []fieldRef{{Flag: flagName, Name: args[1], Position: offset + 2, Kind: fieldMeta.Kind}}
+		case FieldTypeMulti:
+			var refs []fieldRef
+			for i, argSpec := range fieldMeta.Args {
+				if argSpec.Type == ArgumentTypeField {
+					refs = append(refs, fieldRef{Flag: flagName, Name: args[i+1], Position: offset + i + 2, Kind: fieldMeta.Kind})
+				}
+			}
+			return refs
+		}
+		return nil
+	}
+	return nil
+}
+
+// checkFieldRefs reports every field name missing from the input's header,
+// and every field whose column is not of the kind its flag accepts. stdin
+// reports whether standard input is the input.
+func (cmd *GSCommand) checkFieldRefs(args []string, refs []fieldRef, stdin bool) []error {
+	header, source, err := cmd.inputHeader(args, stdin)
+	if err != nil {
+		// The Commander reports unreadable input when it opens it
+		return nil
+	}
+
+	known := make(map[string]bool, len(header))
+	for _, name := range header {
+		known[name] = true
+	}
+
+	var errs []error
+	var kinds map[string]tsv.Kind
+	for _, ref := range refs {
+		if !known[ref.Name] {
+			errs = append(errs, ParseError{
+				Field:    ref.Flag,
+				Value:    ref.Name,
+				Message:  UnknownFieldError(ref.Name, source, header).Error(),
+				Position: ref.Position,
+			})
+			continue
+		}
+		if ref.Kind == "" {
+			continue
+		}
+
+		// Infer kinds only when needed; a GS_HEADER hint has none
+		if kinds == nil {
+			if source == headerEnv {
+				return errs
+			}
+			if kinds, err = cmd.ColumnKinds(cmd.findTSVFile(args)); err != nil {
+				return errs
+			}
+		}
+		if kind, ok := kinds[ref.Name]; ok && !kindMatches(ref.Kind, kind) {
+			errs = append(errs, ParseError{
+				Field:    ref.Flag,
+				Value:    ref.Name,
+				Message:  fmt.Sprintf("field '%s' in %s is %s, not %s", ref.Name, source, kind, ref.Kind),
+				Position: ref.Position,
+			})
+		}
+	}
+	return errs
+}
+
+// inputHeader returns the field names of the input and a name for it
+func (cmd *GSCommand) inputHeader(args []string, stdin bool) ([]string, string, error) {
+	if filename := cmd.findTSVFile(args); filename != "" {
+		header, err := cmd.getFields(filename, false)
+		return header, filename, err
+	}
+	if !stdin {
+		// Reading stdin would block on, or consume, input meant for nothing
+		return nil, "", errors.New("input is not a TSV or CSV file")
+	}
+
+	header, _, err := cmd.PeekStdin(0)
+	if err != nil {
+		// With nothing piped, fall back on the GS_HEADER hint
+		if _, hint := cmd.pipedInput(); hint != nil {
+			return hint, headerEnv, nil
+		}
+		return nil, "", err
+	}
+	return header, "stdin", nil
+}
+
+// readsStdin reports whether parsing left standard input as the input: no
+// -argv or positional argument names anything but "-"
+func readsStdin(clauses []ClauseSet) bool {
+	for _, clause := range clauses {
+		if argv, ok := clause.Fields["Argv"].(string); ok && argv != "" && argv != "-" {
+			return false
+		}
+		for _, arg := range getStringSlice(clause.Fields["_args"]) {
+			if arg != "-" {
+				return false
+			}
+		}
+	}
+	return true
+}